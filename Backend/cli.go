@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/passwords"
+	"time"
+)
+
+// runCommand executes a CLI subcommand such as `create-admin` or `migrate`.
+// It reports false when args do not name a subcommand, in which case the
+// caller should start the HTTP server.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "create-admin":
+		createAdmin(args[1:])
+	case "rebuild-search":
+		rebuildSearch()
+	case "migrate":
+		migrate(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	return true
+}
+
+const usage = `usage: shopping-cart-backend [command]
+
+Without a command the HTTP server is started. Commands:
+  create-admin -username NAME [-password PASS]  create or promote an admin
+  rebuild-search                                rebuild the item search index
+  migrate up                                    apply pending migrations
+  migrate down [-steps N]                       revert the last N migrations (default 1)
+  migrate status                                list migrations and whether they are applied
+`
+
+// migrate runs `migrate up|down|status` against the configured database
+// without applying migrations on connect
+func migrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := database.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("Invalid database configuration:", err)
+	}
+	db, err := database.Open(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(db)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		fs.Parse(args[1:])
+
+		reverted, err := database.MigrateDown(db, *steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no migrations to revert")
+		}
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-24s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n", args[0])
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// createAdmin creates or promotes an admin user. The password falls back to
+// ADMIN_PASSWORD so it does not have to appear in shell history.
+func createAdmin(args []string) {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := fs.String("username", "", "admin username")
+	password := fs.String("password", os.Getenv("ADMIN_PASSWORD"), "admin password (defaults to $ADMIN_PASSWORD)")
+	fs.Parse(args)
+
+	database.Connect()
+	if err := passwords.LoadPolicy(); err != nil {
+		log.Fatal("Invalid password policy:", err)
+	}
+	if err := database.EnsureAdmin(*username, *password); err != nil {
+		log.Fatal("Failed to create admin:", err)
+	}
+	log.Printf("User %q is an admin", *username)
+}
+
+// rebuildSearch repopulates the item search index, e.g. after restoring a
+// database copied from elsewhere or bulk-loading items with triggers disabled
+func rebuildSearch() {
+	database.Connect()
+	if err := database.RebuildItemSearch(database.DB); err != nil {
+		log.Fatal("Failed to rebuild search index:", err)
+	}
+	log.Println("Item search index rebuilt")
+}
+
+// bootstrapAdmin creates the first admin from ADMIN_USERNAME/ADMIN_PASSWORD
+// when those are set, so a fresh deployment has someone to manage the catalog.
+// An existing user with that name is never promoted, or whoever registered
+// it first would become admin; promoting takes an explicit create-admin.
+func bootstrapAdmin() {
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		return
+	}
+	err := database.CreateAdmin(username, os.Getenv("ADMIN_PASSWORD"))
+	if errors.Is(err, database.ErrUsernameTaken) {
+		log.Printf("WARNING: ADMIN_USERNAME %q belongs to an existing non-admin user and was not promoted; run create-admin to promote it", username)
+		return
+	}
+	if err != nil {
+		log.Fatal("Failed to bootstrap admin:", err)
+	}
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/passwords"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrUsernameTaken is returned by CreateAdmin when the username belongs to a
+// user who is not an admin
+var ErrUsernameTaken = errors.New("username belongs to an existing non-admin user")
+
+// EnsureAdmin makes sure username exists with the admin role. An existing
+// user is promoted and keeps their password; otherwise a new admin is
+// created with the given password.
+func EnsureAdmin(username, password string) error {
+	err := CreateAdmin(username, password)
+	if !errors.Is(err, ErrUsernameTaken) {
+		return err
+	}
+	return DB.Model(&models.User{}).Where("username = ?", username).Update("role", models.RoleAdmin).Error
+}
+
+// CreateAdmin creates username as an admin with the given password. It never
+// promotes an existing user: if username is already an admin there is
+// nothing to do, and if it belongs to anyone else it returns ErrUsernameTaken.
+func CreateAdmin(username, password string) error {
+	if username == "" {
+		return errors.New("admin username is required")
+	}
+
+	var user models.User
+	err := DB.Where("username = ?", username).First(&user).Error
+	if err == nil {
+		if user.Role == models.RoleAdmin {
+			return nil
+		}
+		return ErrUsernameTaken
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if password == "" {
+		return fmt.Errorf("password is required to create admin %q", username)
+	}
+	if err := passwords.Check(password, username); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return DB.Create(&models.User{
+		Username: username,
+		Password: string(hashedPassword),
+		Role:     models.RoleAdmin,
+	}).Error
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"errors"
+	"shopping-cart-backend/models"
+	"testing"
+)
+
+func TestCreateAdminNeverPromotes(t *testing.T) {
+	db, err := Open(Config{Driver: "sqlite", DSN: MemoryDSN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() { DB = previous })
+
+	if err := db.Create(&models.User{Username: "boss", Password: "x", Role: models.RoleCustomer}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	role := func() string {
+		var user models.User
+		if err := db.Where("username = ?", "boss").First(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		return user.Role
+	}
+
+	if err := CreateAdmin("boss", "Adm1n-Sup3r-Secret!"); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("CreateAdmin over an existing customer = %v, want ErrUsernameTaken", err)
+	}
+	if got := role(); got != models.RoleCustomer {
+		t.Fatalf("role after CreateAdmin = %q, want %q", got, models.RoleCustomer)
+	}
+
+	if err := EnsureAdmin("boss", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := role(); got != models.RoleAdmin {
+		t.Fatalf("role after EnsureAdmin = %q, want %q", got, models.RoleAdmin)
+	}
+	if err := CreateAdmin("boss", ""); err != nil {
+		t.Errorf("CreateAdmin for an existing admin = %v, want nil", err)
+	}
+}
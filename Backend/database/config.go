@@ -0,0 +1,173 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// DefaultSQLitePath is the database file used when nothing is configured
+const DefaultSQLitePath = "shopping_cart.db"
+
+// MemoryDSN selects a private in-memory SQLite database, handy for tests
+const MemoryDSN = ":memory:"
+
+// Config selects the database driver and tunes its connection pool.
+// Zero pool values leave database/sql defaults in place.
+type Config struct {
+	Driver string // sqlite, postgres or mysql (or any registered dialector)
+	DSN    string // for sqlite, a file path or MemoryDSN
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DialectorFunc builds a GORM dialector for a DSN
+type DialectorFunc func(dsn string) gorm.Dialector
+
+var dialectors = map[string]DialectorFunc{
+	"sqlite":   openSQLite,
+	"postgres": postgres.Open,
+	"mysql":    mysql.Open,
+}
+
+// RegisterDialector makes another driver selectable through Config.Driver
+func RegisterDialector(driver string, fn DialectorFunc) {
+	dialectors[driver] = fn
+}
+
+// ConfigFromEnv reads the database configuration:
+//
+//	DB_DRIVER              sqlite (default), postgres or mysql
+//	DB_DSN / DATABASE_URL  connection string; for sqlite a file path or ":memory:"
+//	DB_PATH                sqlite file path, used when no DSN is set
+//	DB_MAX_OPEN_CONNS      maximum open connections
+//	DB_MAX_IDLE_CONNS      maximum idle connections
+//	DB_CONN_MAX_LIFETIME   maximum connection age, e.g. "30m"
+//	DB_CONN_MAX_IDLE_TIME  maximum connection idle time, e.g. "5m"
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Driver: strings.ToLower(os.Getenv("DB_DRIVER")),
+		DSN:    os.Getenv("DB_DSN"),
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = "sqlite"
+	}
+	if cfg.DSN == "" {
+		cfg.DSN = os.Getenv("DATABASE_URL")
+	}
+	if cfg.DSN == "" && cfg.Driver == "sqlite" {
+		cfg.DSN = os.Getenv("DB_PATH")
+		if cfg.DSN == "" {
+			cfg.DSN = DefaultSQLitePath
+		}
+	}
+
+	var err error
+	if cfg.MaxOpenConns, err = envInt("DB_MAX_OPEN_CONNS"); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxIdleConns, err = envInt("DB_MAX_IDLE_CONNS"); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxLifetime, err = envDuration("DB_CONN_MAX_LIFETIME"); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnMaxIdleTime, err = envDuration("DB_CONN_MAX_IDLE_TIME"); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Open connects using cfg and applies its pool settings
+func Open(cfg Config) (*gorm.DB, error) {
+	dialector, ok := dialectors[cfg.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("no DSN configured for database driver %q", cfg.Driver)
+	}
+
+	// TranslateError turns each driver's unique-constraint violation into
+	// gorm.ErrDuplicatedKey, so handlers can tell a lost race from a failure
+	db, err := gorm.Open(dialector(cfg.DSN), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each connection to an in-memory SQLite database sees its own empty
+	// database, so the pool must never hold more than one
+	if cfg.Driver == "sqlite" && cfg.DSN == MemoryDSN {
+		cfg.MaxOpenConns = 1
+		cfg.ConnMaxLifetime = 0
+		cfg.ConnMaxIdleTime = 0
+	}
+
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	return db, nil
+}
+
+// IsSQLite reports whether db is backed by SQLite, which enables
+// SQLite-only features such as the FTS5 item search index
+func IsSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == "sqlite"
+}
+
+// openSQLite opens a SQLite file, waiting on locks rather than failing at once
+func openSQLite(dsn string) gorm.Dialector {
+	if dsn != MemoryDSN && !strings.Contains(dsn, "?") {
+		dsn += "?_pragma=busy_timeout(5000)"
+	}
+	return sqlite.Open(dsn)
+}
+
+func envInt(key string) (int, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+func envDuration(key string) (time.Duration, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative duration such as 30m", key)
+	}
+	return d, nil
+}
@@ -2,32 +2,44 @@ package database
 
 import (
 	"log"
-	"shopping-cart-backend/models"
+	"os"
 	
-	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// Connect opens the database configured in the environment (see
+// ConfigFromEnv) and applies pending migrations, unless DB_AUTO_MIGRATE is
+// "false", in which case schema changes are left to `migrate up`
 func Connect() {
-	var err error
-	DB, err = gorm.Open(sqlite.Open("shopping_cart.db"), &gorm.Config{})
+	cfg, err := ConfigFromEnv()
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		log.Fatal("Invalid database configuration:", err)
 	}
 
-	// Auto-migrate the schemas
-	err = DB.AutoMigrate(
-		&models.User{},
-		&models.Item{},
-		&models.Cart{},
-		&models.CartItem{},
-		&models.Order{},
-	)
+	DB, err = Open(cfg)
 	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	if os.Getenv("DB_AUTO_MIGRATE") == "false" {
+		log.Printf("Database (%s) connected; automatic migration disabled", cfg.Driver)
+		return
+	}
+
+	if err := Migrate(DB); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
-	log.Println("Database connected and migrated successfully")
+	log.Printf("Database (%s) connected and migrated successfully", cfg.Driver)
+}
+
+// Migrate applies every pending migration to db
+func Migrate(db *gorm.DB) error {
+	applied, err := MigrateUp(db)
+	for _, m := range applied {
+		log.Printf("Applied migration %d (%s)", m.Version, m.Name)
+	}
+	return err
 }
@@ -0,0 +1,426 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations is the ordered history of the schema. Never edit a migration
+// once it has shipped; add a new one instead. Each migration declares its own
+// copies of the structs it needs, so later changes to models cannot alter
+// what an old migration does.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      baselineUp,
+		Down:    baselineDown,
+	},
+	{
+		Version: 2,
+		Name:    "item_search",
+		Up: func(tx *gorm.DB) error {
+			// The full-text item index relies on SQLite FTS5
+			if !IsSQLite(tx) {
+				return nil
+			}
+			return SetupItemSearch(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if !IsSQLite(tx) {
+				return nil
+			}
+			return DropItemSearch(tx)
+		},
+	},
+	{
+		Version: 3,
+		Name:    "refresh_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&refreshTokenV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&refreshTokenV3{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "sessions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&sessionV4{}); err != nil {
+				return err
+			}
+			// Sessions replace the single token stored on the user
+			return tx.Migrator().DropColumn(&baselineUser{}, "token")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&baselineUser{}, "Token"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&sessionV4{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "revoked_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&revokedTokenV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&revokedTokenV5{})
+		},
+	},
+	{
+		Version: 6,
+		Name:    "login_lockout",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"FailedLoginAttempts", "LockedUntil"} {
+				if err := tx.Migrator().AddColumn(&userLockoutV6{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"failed_login_attempts", "locked_until"} {
+				if err := tx.Migrator().DropColumn(&userLockoutV6{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 7,
+		Name:    "password_reset_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&passwordResetTokenV7{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&passwordResetTokenV7{})
+		},
+	},
+	{
+		Version: 8,
+		Name:    "user_profile",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"Email", "DisplayName"} {
+				if err := tx.Migrator().AddColumn(&userProfileV8{}, field); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().CreateIndex(&userProfileV8{}, "Email")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&userProfileV8{}, "Email"); err != nil {
+				return err
+			}
+			for _, column := range []string{"email", "display_name"} {
+				if err := tx.Migrator().DropColumn(&userProfileV8{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 9,
+		Name:    "order_status",
+		Up: func(tx *gorm.DB) error {
+			// Existing orders start out pending like new ones
+			if err := tx.Migrator().AddColumn(&orderStatusV9{}, "Status"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&orderStatusV9{}, "Status"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateTable(&orderStatusHistoryV9{}); err != nil {
+				return err
+			}
+			// Give them the same initial history entry new orders get
+			return tx.Exec(`INSERT INTO order_status_histories (order_id, from_status, to_status, reason, created_at)
+				SELECT id, '', 'pending', 'Order placed', created_at FROM orders`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&orderStatusHistoryV9{}); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&orderStatusV9{}, "Status"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&orderStatusV9{}, "status")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "order_history_index",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateIndex(&orderUserCreatedV10{}, "idx_orders_user_created")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropIndex(&orderUserCreatedV10{}, "idx_orders_user_created")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "idempotency_records",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&idempotencyRecordV11{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&idempotencyRecordV11{})
+		},
+	},
+	{
+		Version: 12,
+		Name:    "item_sold_out",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&itemSoldOutV12{}, "SoldOut"); err != nil {
+				return err
+			}
+			// Stock reaching zero has always made an item unavailable, so an
+			// unavailable item without stock is taken to be sold out
+			return tx.Model(&itemSoldOutV12{}).
+				Where("stock <= 0 AND status = ?", "unavailable").
+				Update("sold_out", true).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&itemSoldOutV12{}, "sold_out")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "sold_out_without_stock",
+		Up: func(tx *gorm.DB) error {
+			// Items from before stock was tracked have a stock of 0 but were
+			// left available, so they were listed yet could not be bought.
+			// Mark them sold out; restocking puts them back on sale.
+			return tx.Table("items").
+				Where("stock <= 0 AND status = ?", "available").
+				Updates(map[string]interface{}{"status": "unavailable", "sold_out": true}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			// Which items were changed is not recorded, and they are correct
+			// as they are, so there is nothing to undo
+			return nil
+		},
+	},
+	{
+		Version: 14,
+		Name:    "cart_item_name",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&cartItemNameV14{}, "ItemName"); err != nil {
+				return err
+			}
+			// The names at the time existing lines were added are lost; the
+			// current catalog name is the best snapshot left
+			return tx.Exec(`UPDATE cart_items SET item_name =
+				COALESCE((SELECT name FROM items WHERE items.id = cart_items.item_id), '')`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&cartItemNameV14{}, "item_name")
+		},
+	},
+}
+
+// Baseline schema: the users, items, carts, cart_items and orders tables as
+// they stood when versioned migrations were introduced. Databases created
+// earlier by AutoMigrate already match it, and the baseline leaves them as is.
+
+type baselineUser struct {
+	ID        uint   `gorm:"primaryKey"`
+	Username  string `gorm:"unique;not null"`
+	Password  string `gorm:"not null"`
+	Token     string
+	Role      string `gorm:"not null;default:'customer'"`
+	CartID    *uint
+	CreatedAt time.Time
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineItem struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"not null"`
+	Description string
+	Price       int64  `gorm:"not null;default:0"`
+	Currency    string `gorm:"size:3;not null;default:'INR'"`
+	Stock       int    `gorm:"not null;default:0"`
+	Status      string `gorm:"default:'available'"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineItem) TableName() string { return "items" }
+
+type baselineCart struct {
+	ID        uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"not null"`
+	Name      string
+	Status    string `gorm:"default:'active'"`
+	CreatedAt time.Time
+}
+
+func (baselineCart) TableName() string { return "carts" }
+
+type baselineCartItem struct {
+	CartID    uint   `gorm:"primaryKey"`
+	ItemID    uint   `gorm:"primaryKey"`
+	Quantity  int    `gorm:"default:1"`
+	UnitPrice int64  `gorm:"not null;default:0"`
+	Currency  string `gorm:"size:3;not null;default:'INR'"`
+}
+
+func (baselineCartItem) TableName() string { return "cart_items" }
+
+type baselineOrder struct {
+	ID        uint   `gorm:"primaryKey"`
+	CartID    uint   `gorm:"not null"`
+	UserID    uint   `gorm:"not null"`
+	Subtotal  int64  `gorm:"not null;default:0"`
+	Total     int64  `gorm:"not null;default:0"`
+	Currency  string `gorm:"size:3;not null;default:'INR'"`
+	CreatedAt time.Time
+}
+
+func (baselineOrder) TableName() string { return "orders" }
+
+func baselineUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(
+		&baselineUser{},
+		&baselineItem{},
+		&baselineCart{},
+		&baselineCartItem{},
+		&baselineOrder{},
+	)
+}
+
+func baselineDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(
+		&baselineOrder{},
+		&baselineCartItem{},
+		&baselineCart{},
+		&baselineItem{},
+		&baselineUser{},
+	)
+}
+
+type refreshTokenV3 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	FamilyID  string    `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (refreshTokenV3) TableName() string { return "refresh_tokens" }
+
+type sessionV4 struct {
+	ID          string `gorm:"primaryKey;size:32"`
+	UserID      uint   `gorm:"not null;index"`
+	DeviceLabel string
+	IP          string
+	UserAgent   string
+	CreatedAt   time.Time
+	LastSeenAt  time.Time
+	RevokedAt   *time.Time
+}
+
+func (sessionV4) TableName() string { return "sessions" }
+
+type revokedTokenV5 struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	UserID    uint      `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+func (revokedTokenV5) TableName() string { return "revoked_tokens" }
+
+// userLockoutV6 holds only the columns migration 6 adds to users
+type userLockoutV6 struct {
+	FailedLoginAttempts int `gorm:"not null;default:0"`
+	LockedUntil         *time.Time
+}
+
+func (userLockoutV6) TableName() string { return "users" }
+
+type passwordResetTokenV7 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (passwordResetTokenV7) TableName() string { return "password_reset_tokens" }
+
+// userProfileV8 holds only the columns migration 8 adds to users
+type userProfileV8 struct {
+	Email       *string `gorm:"uniqueIndex;size:254"`
+	DisplayName string  `gorm:"size:100"`
+}
+
+func (userProfileV8) TableName() string { return "users" }
+
+// orderStatusV9 holds only the column migration 9 adds to orders
+type orderStatusV9 struct {
+	Status string `gorm:"not null;default:'pending';index"`
+}
+
+func (orderStatusV9) TableName() string { return "orders" }
+
+type orderStatusHistoryV9 struct {
+	ID          uint `gorm:"primaryKey"`
+	OrderID     uint `gorm:"not null;index"`
+	FromStatus  string
+	ToStatus    string `gorm:"not null"`
+	ChangedByID *uint
+	Reason      string
+	CreatedAt   time.Time
+}
+
+func (orderStatusHistoryV9) TableName() string { return "order_status_histories" }
+
+// orderUserCreatedV10 declares the index migration 10 adds to orders for
+// paging through one user's orders newest first
+type orderUserCreatedV10 struct {
+	UserID    uint      `gorm:"index:idx_orders_user_created,priority:1"`
+	CreatedAt time.Time `gorm:"index:idx_orders_user_created,priority:2"`
+}
+
+func (orderUserCreatedV10) TableName() string { return "orders" }
+
+type idempotencyRecordV11 struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string `gorm:"column:idempotency_key;size:255;not null;uniqueIndex:idx_idempotency_user_key"`
+	RequestHash  string `gorm:"size:64;not null"`
+	StatusCode   int    `gorm:"not null;default:0"`
+	ContentType  string
+	ResponseBody []byte
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+func (idempotencyRecordV11) TableName() string { return "idempotency_records" }
+
+// itemSoldOutV12 holds only the column migration 12 adds to items
+type itemSoldOutV12 struct {
+	SoldOut bool `gorm:"not null;default:false"`
+}
+
+func (itemSoldOutV12) TableName() string { return "items" }
+
+// cartItemNameV14 holds only the column migration 14 adds to cart_items
+type cartItemNameV14 struct {
+	ItemName string `gorm:"size:100;not null;default:''"`
+}
+
+func (cartItemNameV14) TableName() string { return "cart_items" }
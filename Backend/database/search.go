@@ -0,0 +1,66 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// itemSearchSchema creates the items_fts full-text index over items.name and
+// items.description. It is an external-content FTS5 table, so the text lives
+// only in items; the triggers keep the index in step with every insert,
+// delete and name/description update, whether it comes from GORM or raw SQL.
+var itemSearchSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS items_fts USING fts5(
+		name, description,
+		content='items', content_rowid='id',
+		tokenize='unicode61 remove_diacritics 2',
+		prefix='2 3'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS items_fts_ai AFTER INSERT ON items BEGIN
+		INSERT INTO items_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS items_fts_ad AFTER DELETE ON items BEGIN
+		INSERT INTO items_fts(items_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS items_fts_au AFTER UPDATE OF name, description ON items BEGIN
+		INSERT INTO items_fts(items_fts, rowid, name, description) VALUES ('delete', old.id, old.name, old.description);
+		INSERT INTO items_fts(rowid, name, description) VALUES (new.id, new.name, new.description);
+	END`,
+}
+
+// SetupItemSearch creates the item search index if it is missing. A newly
+// created index is rebuilt at once so items that already exist are searchable.
+func SetupItemSearch(db *gorm.DB) error {
+	created := !db.Migrator().HasTable("items_fts")
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range itemSearchSchema {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		if created {
+			return RebuildItemSearch(tx)
+		}
+		return nil
+	})
+}
+
+// RebuildItemSearch repopulates the search index from the items table
+func RebuildItemSearch(db *gorm.DB) error {
+	return db.Exec("INSERT INTO items_fts(items_fts) VALUES ('rebuild')").Error
+}
+
+// DropItemSearch removes the item search index and its triggers
+func DropItemSearch(db *gorm.DB) error {
+	for _, statement := range []string{
+		"DROP TRIGGER IF EXISTS items_fts_au",
+		"DROP TRIGGER IF EXISTS items_fts_ad",
+		"DROP TRIGGER IF EXISTS items_fts_ai",
+		"DROP TABLE IF EXISTS items_fts",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
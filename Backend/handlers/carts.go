@@ -4,49 +4,61 @@ import (
 	"net/http"
 	"shopping-cart-backend/database"
 	"shopping-cart-backend/models"
-	
+	"shopping-cart-backend/problem"
+
 	"github.com/gin-gonic/gin"
 )
 
+// MaxQuantityPerItem caps how many units of a single item one cart may hold
+const MaxQuantityPerItem = 10
+
 type AddItemToCartRequest struct {
-	ItemID uint `json:"item_id" binding:"required"`
+	ItemID   uint `json:"item_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"omitempty,gte=1"` // defaults to 1
+}
+
+type UpdateCartItemRequest struct {
+	Quantity *int `json:"quantity" binding:"required,gte=0"` // 0 removes the line
 }
 
 // CreateCart handles POST /carts (add item to cart)
 func CreateCart(c *gin.Context) {
 	userID := c.GetUint("user_id")
-	
+
 	var req AddItemToCartRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(problem.Binding(err))
 		return
 	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
 
 	// Check if item exists and is available
 	var item models.Item
 	if err := database.DB.First(&item, req.ItemID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		c.Error(problem.New(http.StatusNotFound, problem.CodeItemNotFound, "Item not found"))
 		return
 	}
 
-	if item.Status != "available" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Item not available"})
+	if item.Status != models.ItemStatusAvailable {
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeItemUnavailable, "Item not available"))
 		return
 	}
 
 	// Get or create user's active cart
 	var cart models.Cart
-	err := database.DB.Where("user_id = ? AND status = ?", userID, "active").First(&cart).Error
-	
+	err := database.DB.Where("user_id = ? AND status = ?", userID, models.CartStatusActive).First(&cart).Error
+
 	if err != nil {
 		// Create new cart
 		cart = models.Cart{
 			UserID: userID,
 			Name:   "Shopping Cart",
-			Status: "active",
+			Status: models.CartStatusActive,
 		}
 		if err := database.DB.Create(&cart).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cart"})
+			c.Error(problem.Internal("Failed to create cart", err))
 			return
 		}
 
@@ -54,40 +66,61 @@ func CreateCart(c *gin.Context) {
 		database.DB.Model(&models.User{}).Where("id = ?", userID).Update("cart_id", cart.ID)
 	}
 
+	// All lines in a cart must share one currency so the order total is meaningful
+	var mismatched int64
+	database.DB.Model(&models.CartItem{}).
+		Where("cart_id = ? AND currency <> ?", cart.ID, item.Currency).
+		Count(&mismatched)
+	if mismatched > 0 {
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeCurrencyMismatch, "Item currency does not match items already in cart"))
+		return
+	}
+
 	// Check if item already in cart
 	var existingCartItem models.CartItem
 	if err := database.DB.Where("cart_id = ? AND item_id = ?", cart.ID, req.ItemID).First(&existingCartItem).Error; err == nil {
 		// Item exists, increase quantity
-		existingCartItem.Quantity++
+		if !checkCartQuantity(c, item, existingCartItem.Quantity+req.Quantity) {
+			return
+		}
+		existingCartItem.Quantity += req.Quantity
 		if err := database.DB.Save(&existingCartItem).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update item quantity"})
+			c.Error(problem.Internal("Failed to update item quantity", err))
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Item quantity updated in cart",
-			"cart_id": cart.ID,
-			"item_id": req.ItemID,
+			"message":  "Item quantity updated in cart",
+			"cart_id":  cart.ID,
+			"item_id":  req.ItemID,
 			"quantity": existingCartItem.Quantity,
 		})
 		return
 	}
 
+	if !checkCartQuantity(c, item, req.Quantity) {
+		return
+	}
+
 	// Add new item to cart
 	cartItem := models.CartItem{
-		CartID:   cart.ID,
-		ItemID:   req.ItemID,
-		Quantity: 1,
+		CartID:    cart.ID,
+		ItemID:    req.ItemID,
+		Quantity:  req.Quantity,
+		ItemName:  item.Name,
+		UnitPrice: item.Price,
+		Currency:  item.Currency,
 	}
 
 	if err := database.DB.Create(&cartItem).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to cart"})
+		c.Error(problem.Internal("Failed to add item to cart", err))
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Item added to cart successfully",
-		"cart_id": cart.ID,
-		"item_id": req.ItemID,
+		"message":  "Item added to cart successfully",
+		"cart_id":  cart.ID,
+		"item_id":  req.ItemID,
+		"quantity": cartItem.Quantity,
 	})
 }
 
@@ -95,17 +128,78 @@ func CreateCart(c *gin.Context) {
 func GetCart(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
-	var cart models.Cart
-	if err := database.DB.Where("user_id = ? AND status = ?", userID, "active").
-		Preload("Items").
-		Preload("CartItems.Item").First(&cart).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No active cart found"})
+	cart, err := loadActiveCart(userID)
+	if err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeCartNotFound, "No active cart found"))
 		return
 	}
 
 	c.JSON(http.StatusOK, cart)
 }
 
+// UpdateCartItem handles PUT /carts/:itemId (set item quantity, 0 removes it)
+func UpdateCartItem(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	itemID := c.Param("itemId")
+
+	var req UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	// Get user's active cart
+	var cart models.Cart
+	if err := database.DB.Where("user_id = ? AND status = ?", userID, models.CartStatusActive).First(&cart).Error; err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeCartNotFound, "No active cart found"))
+		return
+	}
+
+	var cartItem models.CartItem
+	if err := database.DB.Where("cart_id = ? AND item_id = ?", cart.ID, itemID).First(&cartItem).Error; err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeCartItemNotFound, "Item not found in cart"))
+		return
+	}
+
+	if *req.Quantity == 0 {
+		if err := database.DB.Delete(&cartItem).Error; err != nil {
+			c.Error(problem.Internal("Failed to remove item from cart", err))
+			return
+		}
+	} else {
+		// Only more units need an item that is still on sale with enough
+		// stock; lowering the quantity is always allowed
+		if *req.Quantity > cartItem.Quantity {
+			var item models.Item
+			if err := database.DB.First(&item, cartItem.ItemID).Error; err != nil {
+				c.Error(problem.New(http.StatusNotFound, problem.CodeItemNotFound, "Item not found"))
+				return
+			}
+			if item.Status != models.ItemStatusAvailable {
+				c.Error(problem.New(http.StatusBadRequest, problem.CodeItemUnavailable, "Item not available"))
+				return
+			}
+			if !checkCartQuantity(c, item, *req.Quantity) {
+				return
+			}
+		}
+
+		cartItem.Quantity = *req.Quantity
+		if err := database.DB.Save(&cartItem).Error; err != nil {
+			c.Error(problem.Internal("Failed to update item quantity", err))
+			return
+		}
+	}
+
+	updated, err := loadActiveCart(userID)
+	if err != nil {
+		c.Error(problem.Internal("Failed to load cart", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
 // RemoveFromCart handles DELETE /carts/:itemId (remove item from cart)
 func RemoveFromCart(c *gin.Context) {
 	userID := c.GetUint("user_id")
@@ -113,21 +207,21 @@ func RemoveFromCart(c *gin.Context) {
 
 	// Get user's active cart
 	var cart models.Cart
-	if err := database.DB.Where("user_id = ? AND status = ?", userID, "active").First(&cart).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No active cart found"})
+	if err := database.DB.Where("user_id = ? AND status = ?", userID, models.CartStatusActive).First(&cart).Error; err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeCartNotFound, "No active cart found"))
 		return
 	}
 
 	// Find and remove the cart item
 	var cartItem models.CartItem
 	if err := database.DB.Where("cart_id = ? AND item_id = ?", cart.ID, itemID).First(&cartItem).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found in cart"})
+		c.Error(problem.New(http.StatusNotFound, problem.CodeCartItemNotFound, "Item not found in cart"))
 		return
 	}
 
 	// Delete the cart item
 	if err := database.DB.Delete(&cartItem).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item from cart"})
+		c.Error(problem.Internal("Failed to remove item from cart", err))
 		return
 	}
 
@@ -137,3 +231,32 @@ func RemoveFromCart(c *gin.Context) {
 		"item_id": itemID,
 	})
 }
+
+// loadActiveCart fetches the user's active cart with its lines and subtotal
+func loadActiveCart(userID uint) (*CartResponse, error) {
+	var cart models.Cart
+	if err := database.DB.Where("user_id = ? AND status = ?", userID, models.CartStatusActive).
+		Preload("Items", withDeleted).
+		Preload("CartItems.Item", withDeleted).First(&cart).Error; err != nil {
+		return nil, err
+	}
+
+	response := newCartResponse(&cart)
+	return &response, nil
+}
+
+// checkCartQuantity reports whether a cart line may hold quantity units of
+// item, recording the error when it may not
+func checkCartQuantity(c *gin.Context, item models.Item, quantity int) bool {
+	if quantity > MaxQuantityPerItem {
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeQuantityLimit, "Quantity exceeds per-item maximum").
+			With("max_quantity", MaxQuantityPerItem))
+		return false
+	}
+	if quantity > item.Stock {
+		c.Error(problem.New(http.StatusConflict, problem.CodeOutOfStock, "Insufficient stock").
+			With("available", item.Stock))
+		return false
+	}
+	return true
+}
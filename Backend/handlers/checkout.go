@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"shopping-cart-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Checkout error contract: Checkout returns one of these for conditions the
+// caller caused, and any other error for storage failures. In every error
+// case the surrounding transaction is rolled back, so no order, cart status
+// change, stock decrement or user update is ever partially committed.
+var (
+	ErrNoActiveCart = errors.New("no active cart found")
+	ErrCartEmpty    = errors.New("cart is empty")
+)
+
+// InsufficientStockError reports a cart line that cannot be fulfilled from stock
+type InsufficientStockError struct {
+	ItemID uint
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for item %d", e.ItemID)
+}
+
+// ItemUnavailableError reports a cart line whose item is no longer sold:
+// an admin took it off sale, or Removed when it was deleted from the catalog
+type ItemUnavailableError struct {
+	ItemID  uint
+	Removed bool
+}
+
+func (e *ItemUnavailableError) Error() string {
+	if e.Removed {
+		return fmt.Sprintf("item %d was removed from the catalog", e.ItemID)
+	}
+	return fmt.Sprintf("item %d is not available", e.ItemID)
+}
+
+// Checkout converts the user's active cart into an order. Every write happens
+// inside a single transaction on db: claiming the cart, creating the order,
+// decrementing stock and clearing the user's cart_id.
+func Checkout(db *gorm.DB, userID uint) (*models.Order, error) {
+	var order models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var cart models.Cart
+		if err := tx.Where("user_id = ? AND status = ?", userID, models.CartStatusActive).First(&cart).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNoActiveCart
+			}
+			return err
+		}
+
+		var cartItems []models.CartItem
+		if err := tx.Where("cart_id = ?", cart.ID).Find(&cartItems).Error; err != nil {
+			return err
+		}
+		if len(cartItems) == 0 {
+			return ErrCartEmpty
+		}
+
+		// Claim the cart; a concurrent checkout of the same cart matches no rows
+		result := tx.Model(&models.Cart{}).
+			Where("id = ? AND status = ?", cart.ID, models.CartStatusActive).
+			Update("status", models.CartStatusOrdered)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNoActiveCart
+		}
+
+		// Price the order from the snapshots taken when items were added
+		var subtotal int64
+		for _, cartItem := range cartItems {
+			subtotal += cartItem.LineTotal()
+		}
+
+		order = models.Order{
+			CartID:   cart.ID,
+			UserID:   userID,
+			Subtotal: subtotal,
+			Total:    subtotal,
+			Currency: cartItems[0].Currency,
+			Status:   models.OrderStatusPending,
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if err := recordOrderStatus(tx, order.ID, "", order.Status, &userID, "Order placed"); err != nil {
+			return err
+		}
+
+		for _, cartItem := range cartItems {
+			if err := decrementStock(tx, cartItem.ItemID, cartItem.Quantity); err != nil {
+				return err
+			}
+		}
+
+		// Clear user's cart_id (so they can create a new cart)
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("cart_id", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// decrementStock takes quantity units of an available item out of stock,
+// marking the item unavailable and sold out when it runs out. The guards in
+// the WHERE clause make the check-and-decrement a single atomic statement.
+func decrementStock(tx *gorm.DB, itemID uint, quantity int) error {
+	result := tx.Model(&models.Item{}).
+		Where("id = ? AND status = ? AND stock >= ?", itemID, models.ItemStatusAvailable, quantity).
+		Updates(map[string]interface{}{
+			"stock":  gorm.Expr("stock - ?", quantity),
+			"status": gorm.Expr("CASE WHEN stock - ? <= 0 THEN ? ELSE status END", quantity, models.ItemStatusUnavailable),
+			"sold_out": gorm.Expr("CASE WHEN stock - ? <= 0 AND status = ? THEN ? ELSE sold_out END",
+				quantity, models.ItemStatusAvailable, true),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return stockFailure(tx, itemID)
+	}
+	return nil
+}
+
+// stockFailure explains why decrementStock matched no row. Sold-out items
+// are unavailable too, but for them running out of stock is the reason.
+func stockFailure(tx *gorm.DB, itemID uint) error {
+	var item models.Item
+	err := tx.Unscoped().First(&item, itemID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &ItemUnavailableError{ItemID: itemID, Removed: true}
+	case err != nil:
+		return err
+	case item.DeletedAt.Valid:
+		return &ItemUnavailableError{ItemID: itemID, Removed: true}
+	case item.Status != models.ItemStatusAvailable && !item.SoldOut:
+		return &ItemUnavailableError{ItemID: itemID}
+	}
+	return &InsufficientStockError{ItemID: itemID}
+}
+
+// restoreStock puts the quantities of an order's cart lines back into stock,
+// the reverse of decrementStock: an item that was sold out becomes available
+// again. One an admin made unavailable stays so. Items are matched even when
+// soft-deleted.
+func restoreStock(tx *gorm.DB, cartID uint) error {
+	var cartItems []models.CartItem
+	if err := tx.Where("cart_id = ?", cartID).Find(&cartItems).Error; err != nil {
+		return err
+	}
+	for _, cartItem := range cartItems {
+		if err := tx.Unscoped().Model(&models.Item{}).
+			Where("id = ?", cartItem.ItemID).
+			Update("stock", gorm.Expr("stock + ?", cartItem.Quantity)).Error; err != nil {
+			return err
+		}
+		if err := reopenSoldOut(tx.Unscoped(), cartItem.ItemID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reopenSoldOut makes an item that was sold out available again. It is a
+// separate statement because MySQL applies SET assignments in order, so
+// status and sold_out cannot safely be updated together from sold_out.
+func reopenSoldOut(tx *gorm.DB, itemID uint) error {
+	return tx.Model(&models.Item{}).
+		Where("id = ? AND sold_out = ? AND stock > 0", itemID, true).
+		Updates(map[string]interface{}{
+			"status":   models.ItemStatusAvailable,
+			"sold_out": false,
+		}).Error
+}
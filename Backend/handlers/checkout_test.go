@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"errors"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+var errInjected = errors.New("injected failure")
+
+// newTestDB opens a migrated in-memory SQLite database
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Open(database.Config{Driver: "sqlite", DSN: database.MemoryDSN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// checkoutFixture is a user with an active two-line cart
+type checkoutFixture struct {
+	user  models.User
+	cart  models.Cart
+	items [2]models.Item
+}
+
+func newCheckoutFixture(t *testing.T, db *gorm.DB, stock [2]int, quantities [2]int) checkoutFixture {
+	t.Helper()
+	var f checkoutFixture
+	f.user = models.User{Username: "alice", Password: "x"}
+	mustCreate(t, db, &f.user)
+	f.cart = models.Cart{UserID: f.user.ID, Name: "Shopping Cart", Status: models.CartStatusActive}
+	mustCreate(t, db, &f.cart)
+	for i := range f.items {
+		f.items[i] = models.Item{Name: "item", Price: 100, Currency: "INR", Stock: stock[i], Status: models.ItemStatusAvailable}
+		mustCreate(t, db, &f.items[i])
+		mustCreate(t, db, &models.CartItem{
+			CartID: f.cart.ID, ItemID: f.items[i].ID, ItemName: "item", Quantity: quantities[i], UnitPrice: 100, Currency: "INR",
+		})
+	}
+	if err := db.Model(&f.user).Update("cart_id", f.cart.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, value interface{}) {
+	t.Helper()
+	if err := db.Create(value).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkoutState is everything Checkout writes
+type checkoutState struct {
+	cartStatus string
+	orders     int64
+	histories  int64
+	stock      [2]int
+	userCartID *uint
+}
+
+func readCheckoutState(t *testing.T, db *gorm.DB, f checkoutFixture) checkoutState {
+	t.Helper()
+	var s checkoutState
+	var cart models.Cart
+	var user models.User
+	if err := db.First(&cart, f.cart.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&user, f.user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.cartStatus = cart.Status
+	s.userCartID = user.CartID
+	db.Model(&models.Order{}).Count(&s.orders)
+	db.Model(&models.OrderStatusHistory{}).Count(&s.histories)
+	for i, item := range f.items {
+		var current models.Item
+		if err := db.Unscoped().First(&current, item.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		s.stock[i] = current.Stock
+	}
+	return s
+}
+
+func assertUnchanged(t *testing.T, before, after checkoutState) {
+	t.Helper()
+	if after.cartStatus != before.cartStatus {
+		t.Errorf("cart status = %q, want %q", after.cartStatus, before.cartStatus)
+	}
+	if after.orders != before.orders {
+		t.Errorf("orders = %d, want %d", after.orders, before.orders)
+	}
+	if after.histories != before.histories {
+		t.Errorf("status history rows = %d, want %d", after.histories, before.histories)
+	}
+	if after.stock != before.stock {
+		t.Errorf("stock = %v, want %v", after.stock, before.stock)
+	}
+	if (after.userCartID == nil) != (before.userCartID == nil) ||
+		(after.userCartID != nil && *after.userCartID != *before.userCartID) {
+		t.Errorf("users.cart_id = %v, want %v", after.userCartID, before.userCartID)
+	}
+}
+
+// failOn makes the nth create or update of table fail
+func failOn(t *testing.T, db *gorm.DB, operation, table string, nth int) {
+	t.Helper()
+	calls := 0
+	inject := func(tx *gorm.DB) {
+		if tx.Statement.Table != table {
+			return
+		}
+		calls++
+		if calls == nth {
+			tx.AddError(errInjected)
+		}
+	}
+
+	var err error
+	switch operation {
+	case "create":
+		err = db.Callback().Create().Before("gorm:create").Register("test:fail", inject)
+	case "update":
+		err = db.Callback().Update().Before("gorm:update").Register("test:fail", inject)
+	default:
+		t.Fatalf("unknown operation %q", operation)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckoutRollsBackOnFailure(t *testing.T) {
+	steps := []struct {
+		name      string
+		operation string
+		table     string
+		nth       int
+	}{
+		{"claim cart", "update", "carts", 1},
+		{"create order", "create", "orders", 1},
+		{"write status history", "create", "order_status_histories", 1},
+		{"decrement first line", "update", "items", 1},
+		{"decrement second line", "update", "items", 2},
+		{"reset user cart_id", "update", "users", 1},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			db := newTestDB(t)
+			f := newCheckoutFixture(t, db, [2]int{5, 5}, [2]int{2, 3})
+			before := readCheckoutState(t, db, f)
+
+			failOn(t, db, step.operation, step.table, step.nth)
+			if _, err := Checkout(db, f.user.ID); !errors.Is(err, errInjected) {
+				t.Fatalf("Checkout error = %v, want the injected failure", err)
+			}
+
+			assertUnchanged(t, before, readCheckoutState(t, db, f))
+		})
+	}
+}
+
+func TestCheckoutInsufficientStockRollsBackEarlierLines(t *testing.T) {
+	db := newTestDB(t)
+	f := newCheckoutFixture(t, db, [2]int{5, 1}, [2]int{2, 3})
+	before := readCheckoutState(t, db, f)
+
+	_, err := Checkout(db, f.user.ID)
+	var stockErr *InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		t.Fatalf("Checkout error = %v, want InsufficientStockError", err)
+	}
+	if stockErr.ItemID != f.items[1].ID {
+		t.Errorf("InsufficientStockError.ItemID = %d, want %d", stockErr.ItemID, f.items[1].ID)
+	}
+
+	assertUnchanged(t, before, readCheckoutState(t, db, f))
+}
+
+func TestCheckoutCommitsEveryStep(t *testing.T) {
+	db := newTestDB(t)
+	f := newCheckoutFixture(t, db, [2]int{5, 5}, [2]int{2, 3})
+
+	order, err := Checkout(db, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Total != 500 || order.Status != models.OrderStatusPending {
+		t.Errorf("order total %d status %q, want 500 pending", order.Total, order.Status)
+	}
+
+	after := readCheckoutState(t, db, f)
+	if after.cartStatus != models.CartStatusOrdered || after.orders != 1 || after.histories != 1 ||
+		after.stock != [2]int{3, 2} || after.userCartID != nil {
+		t.Errorf("state after checkout = %+v", after)
+	}
+}
+
+func TestCancelRestoresStockAndReopensSoldOutItems(t *testing.T) {
+	db := newTestDB(t)
+	f := newCheckoutFixture(t, db, [2]int{2, 5}, [2]int{2, 3})
+
+	order, err := Checkout(db, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var soldOut models.Item
+	if err := db.First(&soldOut, f.items[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if soldOut.Status != models.ItemStatusUnavailable || !soldOut.SoldOut {
+		t.Fatalf("after checkout item status %q sold out %v, want unavailable and sold out", soldOut.Status, soldOut.SoldOut)
+	}
+
+	// An item an admin took off sale must stay off sale
+	if err := db.Model(&models.Item{}).Where("id = ?", f.items[1].ID).
+		Update("status", models.ItemStatusUnavailable).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return TransitionOrder(tx, order, models.OrderStatusCancelled, nil, "test")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		stock  int
+		status string
+	}{
+		{2, models.ItemStatusAvailable},
+		{5, models.ItemStatusUnavailable},
+	}
+	for i, item := range f.items {
+		var current models.Item
+		if err := db.First(&current, item.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if current.Stock != want[i].stock || current.Status != want[i].status || current.SoldOut {
+			t.Errorf("item %d: stock %d status %q sold out %v, want %d %q false",
+				i, current.Stock, current.Status, current.SoldOut, want[i].stock, want[i].status)
+		}
+	}
+}
+
+func TestOrderResponseKeepsItemSnapshots(t *testing.T) {
+	db := newTestDB(t)
+	f := newCheckoutFixture(t, db, [2]int{5, 5}, [2]int{2, 3})
+	order, err := Checkout(db, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&models.Item{}).Where("id = ?", f.items[0].ID).
+		Updates(map[string]interface{}{"name": "renamed", "price": 999}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Preload("Cart.CartItems.Item").First(order, order.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	response := newOrderResponse(order)
+	if response.Cart == nil || response.Cart.Items != nil {
+		t.Fatalf("order cart = %+v, want cart lines without the items list", response.Cart)
+	}
+	line := response.Cart.CartItems[0]
+	if line.ItemName != "item" || line.UnitPrice != 100 || line.LineTotal != 200 {
+		t.Errorf("line = %+v, want the name and price from when it was added", line)
+	}
+	if line.Item == nil || line.Item.Name != "renamed" {
+		t.Errorf("line item = %+v, want the current catalog item", line.Item)
+	}
+}
+
+func TestCheckoutRejectsItemsNoLongerSold(t *testing.T) {
+	cases := []struct {
+		name        string
+		takeOff     func(db *gorm.DB, item models.Item) error
+		wantRemoved bool
+	}{
+		{"made unavailable", func(db *gorm.DB, item models.Item) error {
+			return db.Model(&item).Update("status", models.ItemStatusUnavailable).Error
+		}, false},
+		{"deleted", func(db *gorm.DB, item models.Item) error {
+			return db.Delete(&item).Error
+		}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			f := newCheckoutFixture(t, db, [2]int{5, 5}, [2]int{2, 3})
+			if err := tc.takeOff(db, f.items[1]); err != nil {
+				t.Fatal(err)
+			}
+			before := readCheckoutState(t, db, f)
+
+			_, err := Checkout(db, f.user.ID)
+			var unavailableErr *ItemUnavailableError
+			if !errors.As(err, &unavailableErr) {
+				t.Fatalf("Checkout error = %v, want ItemUnavailableError", err)
+			}
+			if unavailableErr.ItemID != f.items[1].ID || unavailableErr.Removed != tc.wantRemoved {
+				t.Errorf("ItemUnavailableError = %+v, want item %d removed %v", unavailableErr, f.items[1].ID, tc.wantRemoved)
+			}
+
+			assertUnchanged(t, before, readCheckoutState(t, db, f))
+		})
+	}
+}
+
+func TestCheckoutReportsSoldOutItemsAsOutOfStock(t *testing.T) {
+	db := newTestDB(t)
+	f := newCheckoutFixture(t, db, [2]int{5, 0}, [2]int{2, 3})
+	if err := db.Model(&f.items[1]).Updates(map[string]interface{}{
+		"status": models.ItemStatusUnavailable, "sold_out": true,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Checkout(db, f.user.ID)
+	var stockErr *InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		t.Fatalf("Checkout error = %v, want InsufficientStockError", err)
+	}
+}
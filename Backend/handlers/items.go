@@ -4,47 +4,304 @@ import (
 	"net/http"
 	"shopping-cart-backend/database"
 	"shopping-cart-backend/models"
-	
+	"shopping-cart-backend/problem"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// DefaultCurrency is used for items created without an explicit currency
+const DefaultCurrency = "INR"
+
+// Item requests are limited to prices up to 10,000,000.00 and a stock of a
+// million units, which keeps line totals far from overflowing
 type CreateItemRequest struct {
-	Name   string `json:"name" binding:"required"`
-	Status string `json:"status"`
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=2000"`
+	Price       int64  `json:"price" binding:"gte=0,lte=1000000000"` // minor units
+	Currency    string `json:"currency" binding:"omitempty,currency"`
+	Stock       int    `json:"stock" binding:"gte=0,lte=1000000"`
+	Status      string `json:"status" binding:"omitempty,itemstatus"`
+}
+
+// UpdateItemRequest is a partial update; omitted fields are left unchanged
+type UpdateItemRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=2000"`
+	Price       *int64  `json:"price" binding:"omitempty,gte=0,lte=1000000000"`
+	Currency    *string `json:"currency" binding:"omitempty,currency"`
+	Stock       *int    `json:"stock" binding:"omitempty,gte=0,lte=1000000"`
+	Status      *string `json:"status" binding:"omitempty,itemstatus"`
 }
 
 // CreateItem handles POST /items
 func CreateItem(c *gin.Context) {
 	var req CreateItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(problem.Binding(err))
 		return
 	}
 
-	if req.Status == "" {
-		req.Status = "available"
-	}
-
-	item := models.Item{
-		Name:   req.Name,
-		Status: req.Status,
+	var item models.Item
+	if !applyItemRequest(c, &item, req) {
+		return
 	}
 
 	if err := database.DB.Create(&item).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create item"})
+		c.Error(problem.Internal("Failed to create item", err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, item)
+	c.JSON(http.StatusCreated, newItemResponse(&item))
+}
+
+// itemSortFields maps the ?sort= field names accepted by GetItems to columns
+var itemSortFields = map[string]string{
+	"created_at": "created_at",
+	"name":       "name",
+	"price":      "price",
 }
 
 // GetItems handles GET /items
+//
+// Supports ?page=&limit= pagination, ?status= and ?q= (name substring)
+// filters and ?sort= (created_at, name, price; prefix "-" for descending).
+// The body stays a plain array; pagination metadata is sent in headers.
 func GetItems(c *gin.Context) {
-	var items []models.Item
-	if err := database.DB.Find(&items).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	order, ok := parseSort(c, itemSortFields, "created_at")
+	if !ok {
+		return
+	}
+
+	query := database.DB.Model(&models.Item{})
+	if status := c.Query("status"); status != "" {
+		if !models.ValidItemStatus(status) {
+			c.Error(problem.InvalidParameter("status", "status must be one of: available, unavailable"))
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+	if name := strings.TrimSpace(c.Query("q")); name != "" {
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!'", likePattern(name))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.Error(problem.Internal("Failed to fetch items", err))
+		return
+	}
+
+	items := []models.Item{}
+	if err := query.Order(order).Scopes(page.Scope).Find(&items).Error; err != nil {
+		c.Error(problem.Internal("Failed to fetch items", err))
+		return
+	}
+
+	setPaginationHeaders(c, page, total)
+	c.JSON(http.StatusOK, newItemResponses(items))
+}
+
+// GetItem handles GET /items/:id
+func GetItem(c *gin.Context) {
+	itemID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var item models.Item
+	if err := database.DB.First(&item, itemID).Error; err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeItemNotFound, "Item not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, newItemResponse(&item))
+}
+
+// ReplaceItem handles PUT /items/:id (full update)
+func ReplaceItem(c *gin.Context) {
+	itemID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var item models.Item
+	if err := database.DB.First(&item, itemID).Error; err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeItemNotFound, "Item not found"))
+		return
+	}
+
+	var req CreateItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	if !applyItemRequest(c, &item, req) {
+		return
+	}
+
+	if err := database.DB.Save(&item).Error; err != nil {
+		c.Error(problem.Internal("Failed to update item", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newItemResponse(&item))
+}
+
+// UpdateItem handles PATCH /items/:id (partial update)
+func UpdateItem(c *gin.Context) {
+	itemID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var item models.Item
+	if err := database.DB.First(&item, itemID).Error; err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeItemNotFound, "Item not found"))
+		return
+	}
+
+	var req UpdateItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	// Fill the omitted fields from the current item and apply it as a full
+	// update. A sold-out status was not chosen by an admin, so it is left
+	// for applyItemRequest to work out again from the new stock.
+	full := CreateItemRequest{
+		Name:        item.Name,
+		Description: item.Description,
+		Price:       item.Price,
+		Currency:    item.Currency,
+		Stock:       item.Stock,
+		Status:      item.Status,
+	}
+	if item.SoldOut {
+		full.Status = ""
+	}
+	if req.Name != nil {
+		full.Name = *req.Name
+	}
+	if req.Description != nil {
+		full.Description = *req.Description
+	}
+	if req.Price != nil {
+		full.Price = *req.Price
+	}
+	if req.Currency != nil {
+		full.Currency = *req.Currency
+	}
+	if req.Stock != nil {
+		full.Stock = *req.Stock
+	}
+	if req.Status != nil {
+		full.Status = *req.Status
+	}
+
+	if !applyItemRequest(c, &item, full) {
+		return
+	}
+
+	if err := database.DB.Save(&item).Error; err != nil {
+		c.Error(problem.Internal("Failed to update item", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, items)
+	c.JSON(http.StatusOK, newItemResponse(&item))
+}
+
+// DeleteItem handles DELETE /items/:id (soft delete)
+func DeleteItem(c *gin.Context) {
+	itemID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var item models.Item
+	if err := database.DB.First(&item, itemID).Error; err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeItemNotFound, "Item not found"))
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Drop the item from carts that have not been ordered yet; ordered
+		// carts keep their lines so order history stays intact
+		activeCarts := tx.Model(&models.Cart{}).Select("id").Where("status = ?", models.CartStatusActive)
+		if err := tx.Where("item_id = ? AND cart_id IN (?)", item.ID, activeCarts).
+			Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&item).Error
+	})
+	if err != nil {
+		c.Error(problem.Internal("Failed to delete item", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Item deleted successfully",
+		"item_id": item.ID,
+	})
+}
+
+// applyItemRequest validates req and copies it onto item, recording the
+// error and reporting false when req is invalid
+//
+// An item without stock is taken off sale and marked sold out, so that
+// restocking it, here or by a cancelled order, puts it back on sale. An item
+// an admin made unavailable stays unavailable until an admin says otherwise.
+func applyItemRequest(c *gin.Context, item *models.Item, req CreateItemRequest) bool {
+	soldOut := false
+	switch {
+	case req.Stock == 0 && req.Status != models.ItemStatusUnavailable:
+		req.Status = models.ItemStatusUnavailable
+		soldOut = true
+	case req.Status == "":
+		req.Status = models.ItemStatusAvailable
+	}
+
+	currency, ok := normalizeCurrency(req.Currency)
+	if !ok {
+		c.Error(problem.Invalid("currency", "currency", "currency must be a 3-letter ISO 4217 currency code"))
+		return false
+	}
+
+	item.Name = req.Name
+	item.Description = req.Description
+	item.Price = req.Price
+	item.Currency = currency
+	item.Stock = req.Stock
+	item.Status = req.Status
+	item.SoldOut = soldOut
+	return true
+}
+
+// normalizeCurrency upper-cases a currency code, applying the default when empty
+func normalizeCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency, true
+	}
+	if len(code) != 3 {
+		return "", false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", false
+		}
+	}
+	return code, true
+}
+
+// withDeleted is a Preload condition that includes soft-deleted rows, so
+// carts and orders still show items that were later removed from the catalog
+func withDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
 }
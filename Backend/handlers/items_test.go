@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"shopping-cart-backend/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplyItemRequestStockStatus(t *testing.T) {
+	const (
+		available   = models.ItemStatusAvailable
+		unavailable = models.ItemStatusUnavailable
+	)
+	cases := []struct {
+		name        string
+		stock       int
+		status      string
+		wantStatus  string
+		wantSoldOut bool
+	}{
+		{"in stock defaults to available", 5, "", available, false},
+		{"no stock is sold out", 0, "", unavailable, true},
+		{"no stock overrides available", 0, available, unavailable, true},
+		{"admin unavailable without stock", 0, unavailable, unavailable, false},
+		{"admin unavailable with stock", 5, unavailable, unavailable, false},
+		{"admin available with stock", 5, available, available, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			var item models.Item
+			req := CreateItemRequest{Name: "Pen", Stock: tc.stock, Status: tc.status}
+			if !applyItemRequest(c, &item, req) {
+				t.Fatalf("request rejected: %v", c.Errors)
+			}
+			if item.Status != tc.wantStatus || item.SoldOut != tc.wantSoldOut {
+				t.Errorf("status %q sold out %v, want %q %v", item.Status, item.SoldOut, tc.wantStatus, tc.wantSoldOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"shopping-cart-backend/middleware"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Account lockout, overridable with LOGIN_MAX_FAILED_ATTEMPTS and
+// LOGIN_LOCKOUT_DURATION (a duration such as "15m")
+var (
+	maxFailedLogins = middleware.EnvIntOr("LOGIN_MAX_FAILED_ATTEMPTS", 5)
+	lockoutDuration = middleware.EnvDurationOr("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+)
+
+// lockedFor reports how much longer the account stays locked, or zero
+func lockedFor(user *models.User, now time.Time) time.Duration {
+	if user.LockedUntil == nil || !now.Before(*user.LockedUntil) {
+		return 0
+	}
+	return user.LockedUntil.Sub(now)
+}
+
+// accountLocked records the 429 answer for a login refused for wait
+func accountLocked(c *gin.Context, wait time.Duration) {
+	middleware.SetRetryAfter(c, wait)
+	c.Error(problem.New(http.StatusTooManyRequests, problem.CodeAccountLocked, "Account temporarily locked after too many failed logins"))
+}
+
+// recordFailedLogin counts a wrong password against the account and locks it
+// once maxFailedLogins is reached. It returns the lockout it started, or zero.
+func recordFailedLogin(db *gorm.DB, user *models.User) (time.Duration, error) {
+	// Increment in SQL so concurrent attempts are all counted
+	if err := db.Model(&models.User{}).Where("id = ?", user.ID).
+		Update("failed_login_attempts", gorm.Expr("failed_login_attempts + 1")).Error; err != nil {
+		return 0, err
+	}
+
+	var attempts int
+	if err := db.Model(&models.User{}).Where("id = ?", user.ID).
+		Pluck("failed_login_attempts", &attempts).Error; err != nil {
+		return 0, err
+	}
+	if attempts < maxFailedLogins {
+		return 0, nil
+	}
+
+	// The counter restarts so the account gets a fresh set of attempts once
+	// the lock expires
+	lockedUntil := time.Now().Add(lockoutDuration)
+	err := db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          lockedUntil,
+	}).Error
+	return lockoutDuration, err
+}
+
+// resetFailedLogins clears the failure counter after a successful login
+func resetFailedLogins(db *gorm.DB, user *models.User) error {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return nil
+	}
+	return db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error
+}
+
+// unknownLogins counts failed logins for usernames that have no account, so
+// they lock out exactly like real accounts and the response does not reveal
+// which usernames exist
+var unknownLogins = newLoginFailures(maxUnknownLogins)
+
+// unknownUserHash is compared against when the username does not exist, so
+// the response takes as long as a wrong password for a real account
+var unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("no such user"), bcrypt.DefaultCost)
+
+// unknownLoginRetention is how long an idle counter for an unknown username is
+// kept. Real accounts keep their counter until the next successful login; a
+// day makes waiting it out about as slow as guessing a real account.
+const unknownLoginRetention = 24 * time.Hour
+
+// maxUnknownLogins caps how many unknown usernames are tracked at once, so
+// made-up usernames cannot grow the map without bound. When it is full the
+// longest-idle entry makes room.
+const maxUnknownLogins = 10000
+
+// loginFailures is the in-memory counterpart of users.failed_login_attempts
+// and users.locked_until. Each server instance counts separately.
+type loginFailures struct {
+	maxEntries int
+
+	mu        sync.Mutex
+	entries   map[string]*loginFailure
+	lastSweep time.Time
+}
+
+type loginFailure struct {
+	attempts    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+func newLoginFailures(maxEntries int) *loginFailures {
+	return &loginFailures{maxEntries: maxEntries, entries: make(map[string]*loginFailure)}
+}
+
+// lockedFor reports how much longer username stays locked, or zero
+func (f *loginFailures) lockedFor(username string, now time.Time) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.entries[username]
+	if !ok || !now.Before(entry.lockedUntil) {
+		return 0
+	}
+	return entry.lockedUntil.Sub(now)
+}
+
+// recordFailure counts a failed login for username and locks it once
+// maxFailedLogins is reached, like recordFailedLogin. It returns the lockout
+// it started, or zero.
+func (f *loginFailures) recordFailure(username string, now time.Time) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Drop idle entries now and then, and whenever the map is full
+	if now.Sub(f.lastSweep) > time.Hour || len(f.entries) >= f.maxEntries {
+		for key, entry := range f.entries {
+			if now.Sub(entry.lastFailure) > unknownLoginRetention && !now.Before(entry.lockedUntil) {
+				delete(f.entries, key)
+			}
+		}
+		f.lastSweep = now
+	}
+
+	entry, ok := f.entries[username]
+	if !ok {
+		if len(f.entries) >= f.maxEntries {
+			f.evictIdlest()
+		}
+		entry = &loginFailure{}
+		f.entries[username] = entry
+	}
+	entry.lastFailure = now
+	entry.attempts++
+	if entry.attempts < maxFailedLogins {
+		return 0
+	}
+
+	entry.attempts = 0
+	entry.lockedUntil = now.Add(lockoutDuration)
+	return lockoutDuration
+}
+
+// evictIdlest removes the entry whose last failure is the oldest
+func (f *loginFailures) evictIdlest() {
+	var idlest string
+	var oldest time.Time
+	for key, entry := range f.entries {
+		if idlest == "" || entry.lastFailure.Before(oldest) {
+			idlest, oldest = key, entry.lastFailure
+		}
+	}
+	delete(f.entries, idlest)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUnknownUsernamesLockOutLikeRealAccounts(t *testing.T) {
+	db := useTestDB(t)
+	previous := unknownLogins
+	unknownLogins = newLoginFailures(maxUnknownLogins)
+	t.Cleanup(func() { unknownLogins = previous })
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("Correct-Horse-42"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, db, &models.User{Username: "lockout-real", Password: string(hash)})
+
+	type attempt struct {
+		status     int
+		code       string
+		retryAfter bool // the value counts down, so only its presence is compared
+	}
+	attempts := func(username string) []attempt {
+		var got []attempt
+		for i := 0; i <= maxFailedLogins; i++ {
+			w, code := callHandler(t, Login, http.MethodPost,
+				`{"username":"`+username+`","password":"wrong-password"}`, nil)
+			got = append(got, attempt{w.Code, code, w.Header().Get("Retry-After") != ""})
+		}
+		return got
+	}
+
+	real := attempts("lockout-real")
+	unknown := attempts("lockout-unknown")
+	for i := range real {
+		if real[i] != unknown[i] {
+			t.Errorf("attempt %d: real account %+v, unknown username %+v", i+1, real[i], unknown[i])
+		}
+	}
+	last := unknown[maxFailedLogins-1]
+	if last.status != http.StatusTooManyRequests || last.code != problem.CodeAccountLocked || !last.retryAfter {
+		t.Errorf("attempt %d for an unknown username = %+v, want 429 %s with Retry-After", maxFailedLogins, last, problem.CodeAccountLocked)
+	}
+}
+
+func TestUnknownLoginTrackerIsCapped(t *testing.T) {
+	failures := newLoginFailures(3)
+	start := time.Now()
+	for i, username := range []string{"a", "b", "c", "d"} {
+		failures.recordFailure(username, start.Add(time.Duration(i)*time.Second))
+	}
+
+	if len(failures.entries) != 3 {
+		t.Fatalf("tracking %d usernames, want 3", len(failures.entries))
+	}
+	if _, ok := failures.entries["a"]; ok {
+		t.Error("the longest-idle username was kept")
+	}
+}
@@ -1,71 +1,212 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"shopping-cart-backend/database"
 	"shopping-cart-backend/models"
-	
+	"shopping-cart-backend/problem"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateOrder handles POST /orders (checkout)
 func CreateOrder(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
-	// Get user's active cart
-	var cart models.Cart
-	if err := database.DB.Where("user_id = ? AND status = ?", userID, "active").First(&cart).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No active cart found"})
+	order, err := Checkout(database.DB, userID)
+
+	var stockErr *InsufficientStockError
+	var unavailableErr *ItemUnavailableError
+	switch {
+	case errors.Is(err, ErrNoActiveCart):
+		c.Error(problem.New(http.StatusNotFound, problem.CodeCartNotFound, "No active cart found"))
+		return
+	case errors.Is(err, ErrCartEmpty):
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeCartEmpty, "Cart is empty"))
+		return
+	case errors.As(err, &stockErr):
+		c.Error(problem.New(http.StatusConflict, problem.CodeOutOfStock, "Insufficient stock").
+			With("item_id", stockErr.ItemID))
+		return
+	case errors.As(err, &unavailableErr) && unavailableErr.Removed:
+		c.Error(problem.New(http.StatusConflict, problem.CodeItemRemoved, "Item is no longer sold").
+			With("item_id", unavailableErr.ItemID))
+		return
+	case errors.As(err, &unavailableErr):
+		c.Error(problem.New(http.StatusConflict, problem.CodeItemUnavailable, "Item not available").
+			With("item_id", unavailableErr.ItemID))
+		return
+	case err != nil:
+		c.Error(problem.Internal("Failed to create order", err))
 		return
 	}
 
-	// Check if cart has items
-	var cartItemCount int64
-	database.DB.Model(&models.CartItem{}).Where("cart_id = ?", cart.ID).Count(&cartItemCount)
-	if cartItemCount == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Order created successfully",
+		"order_id": order.ID,
+		"cart_id":  order.CartID,
+		"status":   order.Status,
+		"subtotal": order.Subtotal,
+		"total":    order.Total,
+		"currency": order.Currency,
+	})
+}
+
+// GetOrders handles GET /orders
+//
+// Supports ?page=&limit= pagination, ?status= (comma-separated),
+// ?created_from= and ?created_to= filters, and ?view=summary, which returns
+// totals and item counts instead of the full cart of each order. Orders are
+// newest first; pagination metadata is sent in headers as for GET /items.
+func GetOrders(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
 		return
 	}
 
-	// Create order
-	order := models.Order{
-		CartID: cart.ID,
-		UserID: userID,
+	view := c.DefaultQuery("view", "full")
+	if view != "full" && view != "summary" {
+		c.Error(problem.InvalidParameter("view", "view must be one of: full, summary"))
+		return
 	}
 
-	if err := database.DB.Create(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+	query := database.DB.Model(&models.Order{}).Where("user_id = ?", c.GetUint("user_id"))
+	if raw := c.Query("status"); raw != "" {
+		statuses := strings.Split(raw, ",")
+		for _, status := range statuses {
+			if !models.ValidOrderStatus(status) {
+				c.Error(problem.InvalidParameter("status",
+					"status must be a comma-separated list of: "+strings.Join(models.OrderStatuses, ", ")))
+				return
+			}
+		}
+		query = query.Where("status IN ?", statuses)
+	}
+	from, ok := parseTimeParam(c, "created_from", false)
+	if !ok {
+		return
+	}
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	to, ok := parseTimeParam(c, "created_to", true)
+	if !ok {
 		return
 	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
 
-	// Update cart status to ordered
-	cart.Status = "ordered"
-	if err := database.DB.Save(&cart).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart status"})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.Error(problem.Internal("Failed to fetch orders", err))
 		return
 	}
 
-	// Clear user's cart_id (so they can create a new cart)
-	database.DB.Model(&models.User{}).Where("id = ?", userID).Update("cart_id", nil)
+	query = query.Order("created_at DESC, id DESC").Scopes(page.Scope)
+	if view == "full" {
+		query = query.Preload("Cart").Preload("Cart.CartItems.Item", withDeleted)
+	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message":  "Order created successfully",
-		"order_id": order.ID,
-		"cart_id":  cart.ID,
-	})
+	orders := []models.Order{}
+	if err := query.Find(&orders).Error; err != nil {
+		c.Error(problem.Internal("Failed to fetch orders", err))
+		return
+	}
+
+	setPaginationHeaders(c, page, total)
+
+	if view == "summary" {
+		response, err := newOrderSummaries(database.DB, orders)
+		if err != nil {
+			c.Error(problem.Internal("Failed to fetch orders", err))
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response := make([]OrderResponse, 0, len(orders))
+	for i := range orders {
+		response = append(response, newOrderResponse(&orders[i]))
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-// GetOrders handles GET /orders
-func GetOrders(c *gin.Context) {
-	userID := c.GetUint("user_id")
+// newOrderSummaries counts the lines and units of each order with one
+// grouped query rather than loading every cart
+func newOrderSummaries(db *gorm.DB, orders []models.Order) ([]OrderSummaryResponse, error) {
+	response := make([]OrderSummaryResponse, 0, len(orders))
+	if len(orders) == 0 {
+		return response, nil
+	}
+
+	cartIDs := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		cartIDs = append(cartIDs, order.CartID)
+	}
+
+	var counts []struct {
+		CartID    uint
+		LineCount int
+		ItemCount int
+	}
+	if err := db.Model(&models.CartItem{}).
+		Select("cart_id, COUNT(*) AS line_count, SUM(quantity) AS item_count").
+		Where("cart_id IN ?", cartIDs).
+		Group("cart_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	byCart := make(map[uint]int, len(counts))
+	for i, count := range counts {
+		byCart[count.CartID] = i
+	}
+	for i := range orders {
+		summary := newOrderSummary(&orders[i])
+		if j, ok := byCart[orders[i].CartID]; ok {
+			summary.LineCount = counts[j].LineCount
+			summary.ItemCount = counts[j].ItemCount
+		}
+		response = append(response, summary)
+	}
+	return response, nil
+}
+
+// GetOrder handles GET /orders/:id
+//
+// Customers can only read their own orders. Anyone else's order is reported
+// as not found rather than forbidden, so order IDs cannot be probed.
+func GetOrder(c *gin.Context) {
+	orderID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	user := c.MustGet("user").(models.User)
+	query := database.DB.Where("id = ?", orderID)
+	if user.Role != models.RoleAdmin {
+		query = query.Where("user_id = ?", user.ID)
+	}
 
-	var orders []models.Order
-	if err := database.DB.Where("user_id = ?", userID).
+	var order models.Order
+	if err := query.
 		Preload("Cart").
-		Preload("Cart.CartItems.Item").Find(&orders).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		Preload("Cart.CartItems.Item", withDeleted).
+		Preload("StatusHistory", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC, id ASC")
+		}).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(problem.New(http.StatusNotFound, problem.CodeOrderNotFound, "Order not found"))
+			return
+		}
+		c.Error(problem.Internal("Failed to fetch order", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, orders)
+	c.JSON(http.StatusOK, newOrderResponse(&order))
 }
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CancelOrderRequest is the optional body of POST /orders/:id/cancel
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// UpdateOrderStatusRequest moves an order to a new status
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required,orderstatus"`
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// IllegalTransitionError reports a status change the order state machine
+// does not allow
+type IllegalTransitionError struct {
+	From, To string
+}
+
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+// errOrderStatusChanged means the order's status changed between reading
+// and updating it
+var errOrderStatusChanged = errors.New("order status changed concurrently")
+
+var errOrderNotCancellable = errors.New("order can no longer be cancelled by its owner")
+
+// TransitionOrder moves order to status to and records the change, by
+// changedBy (nil for the system), in the order's history. Cancelling returns
+// the order's items to stock. Every order status change goes through here so
+// the state machine in models is always enforced. It should run inside the
+// caller's transaction.
+func TransitionOrder(tx *gorm.DB, order *models.Order, to string, changedBy *uint, reason string) error {
+	from := order.Status
+	if !models.CanTransitionOrder(from, to) {
+		return &IllegalTransitionError{From: from, To: to}
+	}
+
+	// Guard on the status we validated against, so two concurrent changes
+	// cannot both apply
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ?", order.ID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errOrderStatusChanged
+	}
+
+	if to == models.OrderStatusCancelled {
+		if err := restoreStock(tx, order.CartID); err != nil {
+			return err
+		}
+	}
+	if err := recordOrderStatus(tx, order.ID, from, to, changedBy, reason); err != nil {
+		return err
+	}
+	order.Status = to
+	return nil
+}
+
+func recordOrderStatus(tx *gorm.DB, orderID uint, from, to string, changedBy *uint, reason string) error {
+	return tx.Create(&models.OrderStatusHistory{
+		OrderID:     orderID,
+		FromStatus:  from,
+		ToStatus:    to,
+		ChangedByID: changedBy,
+		Reason:      reason,
+	}).Error
+}
+
+// orderTransitionProblem maps the errors of TransitionOrder to API errors
+func orderTransitionProblem(err error) *problem.Error {
+	var illegal *IllegalTransitionError
+	switch {
+	case errors.As(err, &illegal):
+		return problem.New(http.StatusConflict, problem.CodeInvalidTransition,
+			fmt.Sprintf("Order cannot move from %s to %s", illegal.From, illegal.To)).
+			With("from", illegal.From).
+			With("to", illegal.To).
+			With("allowed", models.NextOrderStatuses(illegal.From))
+	case errors.Is(err, errOrderStatusChanged):
+		return problem.New(http.StatusConflict, problem.CodeInvalidTransition,
+			"Order status changed concurrently; reload the order and retry")
+	}
+	return problem.Internal("Failed to update order status", err)
+}
+
+// UpdateOrderStatus handles PUT /orders/:id/status (admin)
+func UpdateOrderStatus(c *gin.Context) {
+	orderID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req UpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	var order models.Order
+	if err := database.DB.First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(problem.New(http.StatusNotFound, problem.CodeOrderNotFound, "Order not found"))
+			return
+		}
+		c.Error(problem.Internal("Failed to fetch order", err))
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return TransitionOrder(tx, &order, req.Status, &adminID, req.Reason)
+	})
+	if err != nil {
+		c.Error(orderTransitionProblem(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newOrderResponse(&order))
+}
+
+// CancelOrder handles POST /orders/:id/cancel
+//
+// Customers can cancel their own orders while they are pending or paid;
+// admins can cancel any order the state machine allows. The order's items go
+// back into stock, but not into the user's cart.
+func CancelOrder(c *gin.Context) {
+	orderID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req CancelOrderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(problem.Binding(err))
+			return
+		}
+	}
+
+	user := c.MustGet("user").(models.User)
+	isAdmin := user.Role == models.RoleAdmin
+
+	var order models.Order
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("id = ?", orderID)
+		if !isAdmin {
+			query = query.Where("user_id = ?", user.ID)
+		}
+		if err := query.First(&order).Error; err != nil {
+			return err
+		}
+		// Orders that cannot be cancelled at all fall through to the
+		// state machine's error
+		if !isAdmin && !models.CustomerCanCancelOrder(order.Status) &&
+			models.CanTransitionOrder(order.Status, models.OrderStatusCancelled) {
+			return errOrderNotCancellable
+		}
+		return TransitionOrder(tx, &order, models.OrderStatusCancelled, &user.ID, req.Reason)
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.Error(problem.New(http.StatusNotFound, problem.CodeOrderNotFound, "Order not found"))
+		return
+	case errors.Is(err, errOrderNotCancellable):
+		c.Error(problem.New(http.StatusConflict, problem.CodeOrderNotCancellable,
+			fmt.Sprintf("A %s order can only be cancelled by support", order.Status)).
+			With("status", order.Status))
+		return
+	case err != nil:
+		c.Error(orderTransitionProblem(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, newOrderResponse(&order))
+}
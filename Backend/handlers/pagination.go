@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"fmt"
+	"shopping-cart-backend/problem"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+// Page is a 1-based page number and page size parsed from ?page=&limit=
+type Page struct {
+	Number int
+	Size   int
+}
+
+// Offset returns the number of rows to skip for this page
+func (p Page) Offset() int {
+	return (p.Number - 1) * p.Size
+}
+
+// Scope applies the page's LIMIT/OFFSET to a query
+func (p Page) Scope(db *gorm.DB) *gorm.DB {
+	return db.Offset(p.Offset()).Limit(p.Size)
+}
+
+// parsePage reads ?page= and ?limit=, recording a 400 error and reporting
+// false when either is malformed
+func parsePage(c *gin.Context) (Page, bool) {
+	page := Page{Number: 1, Size: DefaultPageSize}
+
+	if raw := c.Query("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.Error(problem.InvalidParameter("page", "page must be a positive integer"))
+			return page, false
+		}
+		page.Number = n
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > MaxPageSize {
+			c.Error(problem.InvalidParameter("limit", fmt.Sprintf("limit must be between 1 and %d", MaxPageSize)))
+			return page, false
+		}
+		page.Size = n
+	}
+
+	return page, true
+}
+
+// parseID reads a positive integer path parameter, recording a 400 error and
+// reporting false when it is malformed
+func parseID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 0)
+	if err != nil || id == 0 {
+		c.Error(problem.InvalidParameter(name, name+" must be a positive integer"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseTimeParam reads an RFC 3339 timestamp or a YYYY-MM-DD date (UTC)
+// from the query, returning the zero time when it is absent. With endOfDay a
+// bare date means the end of that day, so it can be used as an exclusive
+// upper bound that still includes the whole day.
+func parseTimeParam(c *gin.Context, name string, endOfDay bool) (time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, true
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		c.Error(problem.InvalidParameter(name, name+" must be a date (YYYY-MM-DD) or an RFC 3339 timestamp"))
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+// parseSort turns ?sort=name,-price into an ORDER BY clause. allowed maps the
+// public field names to columns; a leading "-" sorts descending. The id is
+// always appended as a tie-breaker so pages are stable.
+func parseSort(c *gin.Context, allowed map[string]string, fallback string) (string, bool) {
+	raw := c.Query("sort")
+	if raw == "" {
+		raw = fallback
+	}
+
+	var clauses []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			field = field[1:]
+		}
+
+		column, ok := allowed[field]
+		if !ok {
+			c.Error(problem.InvalidParameter("sort", fmt.Sprintf("cannot sort by %q", field)))
+			return "", false
+		}
+		clauses = append(clauses, column+" "+direction)
+	}
+
+	return strings.Join(append(clauses, "id ASC"), ", "), true
+}
+
+// setPaginationHeaders reports the total and page position in headers, so
+// the response body can stay a plain array for existing clients. The Link
+// header carries ready-made first/prev/next/last URLs.
+func setPaginationHeaders(c *gin.Context, page Page, total int64) {
+	lastPage := int((total + int64(page.Size) - 1) / int64(page.Size))
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.Header("X-Page", strconv.Itoa(page.Number))
+	c.Header("X-Per-Page", strconv.Itoa(page.Size))
+	c.Header("X-Total-Pages", strconv.Itoa(lastPage))
+
+	link := func(number int, rel string) string {
+		u := *c.Request.URL
+		query := u.Query()
+		query.Set("page", strconv.Itoa(number))
+		query.Set("limit", strconv.Itoa(page.Size))
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.RequestURI(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if page.Number > 1 {
+		links = append(links, link(page.Number-1, "prev"))
+	}
+	if page.Number < lastPage {
+		c.Header("X-Next-Page", strconv.Itoa(page.Number+1))
+		links = append(links, link(page.Number+1, "next"))
+	}
+	links = append(links, link(lastPage, "last"))
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// likePattern builds a case-insensitive substring pattern for LIKE ... ESCAPE '!'.
+// The escape character is not a backslash because MySQL treats a lone
+// backslash inside a string literal as an escape.
+func likePattern(s string) string {
+	s = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(s))
+	return "%" + s + "%"
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/middleware"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/notifier"
+	"shopping-cart-backend/passwords"
+	"shopping-cart-backend/problem"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Password reset settings, overridable with PASSWORD_RESET_TTL (a duration
+// such as "1h") and PASSWORD_RESET_URL, the frontend page the token is
+// appended to in the reset message
+var (
+	passwordResetTTL = middleware.EnvDurationOr("PASSWORD_RESET_TTL", time.Hour)
+	passwordResetURL = middleware.EnvOr("PASSWORD_RESET_URL", "")
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,nefield=CurrentPassword"`
+}
+
+// PasswordResetRequest identifies the account by username or email
+type PasswordResetRequest struct {
+	Username string `json:"username" binding:"required_without=Email"`
+	Email    string `json:"email" binding:"required_without=Username"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+var errResetTokenInvalid = errors.New("reset token invalid, used or expired")
+
+// ChangePassword handles PUT /users/me/password
+//
+// Every other session of the user is logged out; the session making the
+// change stays logged in.
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	user := c.MustGet("user").(models.User)
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		c.Error(problem.New(http.StatusBadRequest, problem.CodePasswordIncorrect, "Current password is incorrect"))
+		return
+	}
+	if err := passwords.Check(req.NewPassword, user.Username); err != nil {
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeWeakPassword, err.Error()))
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := setPassword(tx, user.ID, req.NewPassword); err != nil {
+			return err
+		}
+		return revokeSessions(tx, "user_id = ? AND id <> ?", user.ID, c.GetString("session_id"))
+	})
+	if err != nil {
+		c.Error(problem.Internal("Failed to change password", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed; other sessions were logged out"})
+}
+
+// RequestPasswordReset handles POST /users/password-reset
+//
+// The response is the same whether or not the account exists, so the
+// endpoint cannot be used to discover usernames.
+func RequestPasswordReset(c *gin.Context) {
+	var req PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	accepted := gin.H{"message": "If the account exists, reset instructions have been sent"}
+
+	query := database.DB.Where("username = ?", req.Username)
+	if req.Username == "" {
+		query = database.DB.Where("email = ?", strings.ToLower(strings.TrimSpace(req.Email)))
+	}
+
+	var user models.User
+	if err := query.First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(problem.Internal("Failed to request password reset", err))
+			return
+		}
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	token, err := middleware.RandomToken(32)
+	if err != nil {
+		c.Error(problem.Internal("Failed to request password reset", err))
+		return
+	}
+
+	// Only the newest token works; earlier unused ones are discarded
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).
+			Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: middleware.HashToken(token),
+			ExpiresAt: time.Now().Add(passwordResetTTL),
+		}).Error
+	})
+	if err != nil {
+		c.Error(problem.Internal("Failed to request password reset", err))
+		return
+	}
+
+	// A delivery failure is logged rather than reported, for the same
+	// reason the response does not depend on the account existing
+	if err := notifier.Send(c.Request.Context(), passwordResetMessage(&user, token)); err != nil {
+		log.Printf("Failed to send password reset to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+// ConfirmPasswordReset handles POST /users/password-reset/confirm
+//
+// Consuming the token sets the new password, clears any lockout and logs
+// out every session of the user.
+func ConfirmPasswordReset(c *gin.Context) {
+	var req PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	var reset models.PasswordResetToken
+	if err := database.DB.Where("token_hash = ?", middleware.HashToken(req.Token)).First(&reset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(problem.New(http.StatusBadRequest, problem.CodeResetTokenInvalid, "Invalid or expired reset token"))
+			return
+		}
+		c.Error(problem.Internal("Failed to reset password", err))
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, reset.UserID).Error; err != nil {
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeResetTokenInvalid, "Invalid or expired reset token"))
+		return
+	}
+	if err := passwords.Check(req.NewPassword, user.Username); err != nil {
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeWeakPassword, err.Error()))
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Consume the token; a used or expired one matches no rows
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", reset.ID, time.Now()).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errResetTokenInvalid
+		}
+
+		if err := setPassword(tx, user.ID, req.NewPassword); err != nil {
+			return err
+		}
+		return revokeSessions(tx, "user_id = ?", user.ID)
+	})
+
+	switch {
+	case errors.Is(err, errResetTokenInvalid):
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeResetTokenInvalid, "Invalid or expired reset token"))
+		return
+	case err != nil:
+		c.Error(problem.Internal("Failed to reset password", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset; please log in with the new password"})
+}
+
+// setPassword stores a new password hash and clears any login lockout
+func setPassword(tx *gorm.DB, userID uint, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password":              string(hashedPassword),
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error
+}
+
+func passwordResetMessage(user *models.User, token string) notifier.Message {
+	link := token
+	if passwordResetURL != "" {
+		link = passwordResetURL + token
+	}
+	to := user.Username
+	if user.Email != nil {
+		to = *user.Email
+	}
+	return notifier.Message{
+		UserID:  user.ID,
+		To:      to,
+		Subject: "Reset your password",
+		Body: "Someone asked to reset the password for " + user.Username + ".\n" +
+			"Use this to choose a new one within " + passwordResetTTL.String() + ":\n\n" +
+			link + "\n\nIf this was not you, ignore this message.",
+	}
+}
+
+// PurgeExpiredPasswordResets deletes reset tokens that were used or can no
+// longer be used
+func PurgeExpiredPasswordResets(db *gorm.DB) (int64, error) {
+	result := db.Where("used_at IS NOT NULL OR expires_at <= ?", time.Now()).
+		Delete(&models.PasswordResetToken{})
+	return result.RowsAffected, result.Error
+}
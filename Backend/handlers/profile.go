@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Profile field limits, matching the column sizes
+const (
+	maxEmailLength       = 254
+	maxDisplayNameLength = 100
+)
+
+// ProfileResponse is the view of an account shown to its owner and to
+// admins; credentials and lockout state are never included
+type ProfileResponse struct {
+	ID          uint      `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"display_name"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UpdateProfileRequest changes only the fields that are present; an empty
+// email removes it
+type UpdateProfileRequest struct {
+	Email       *string `json:"email" binding:"omitempty,max=254"`
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+}
+
+var errEmailTaken = errors.New("email already in use")
+
+// GetProfile handles GET /users/me
+func GetProfile(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	c.JSON(http.StatusOK, profileResponse(&user))
+}
+
+// UpdateProfile handles PATCH /users/me
+func UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	user := c.MustGet("user").(models.User)
+	updates := map[string]interface{}{}
+
+	if req.Email != nil {
+		email, ok := normalizeEmail(*req.Email)
+		if !ok {
+			c.Error(problem.Invalid("email", "email", "email must be a valid email address"))
+			return
+		}
+		updates["email"] = email
+	}
+	if req.DisplayName != nil {
+		name, ok := normalizeDisplayName(*req.DisplayName)
+		if !ok {
+			c.Error(problem.Invalid("display_name", "max", "display_name must be at most 100 characters long"))
+			return
+		}
+		updates["display_name"] = name
+	}
+
+	if len(updates) > 0 {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if email, ok := updates["email"].(*string); ok {
+				if err := ensureEmailAvailable(tx, email, user.ID); err != nil {
+					return err
+				}
+			}
+			// The check above can race with another request taking the same
+			// address; the unique index has the final say
+			err := tx.Model(&user).Updates(updates).Error
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return errEmailTaken
+			}
+			return err
+		})
+		if errors.Is(err, errEmailTaken) {
+			c.Error(problem.New(http.StatusConflict, problem.CodeEmailTaken, "Email already in use"))
+			return
+		}
+		if err != nil {
+			c.Error(problem.Internal("Failed to update profile", err))
+			return
+		}
+	}
+
+	if err := database.DB.First(&user, user.ID).Error; err != nil {
+		c.Error(problem.Internal("Failed to fetch profile", err))
+		return
+	}
+	c.JSON(http.StatusOK, profileResponse(&user))
+}
+
+func profileResponse(user *models.User) ProfileResponse {
+	response := ProfileResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Role:        user.Role,
+		CreatedAt:   user.CreatedAt,
+	}
+	if user.Email != nil {
+		response.Email = *user.Email
+	}
+	return response
+}
+
+// normalizeEmail validates a bare address and lower-cases it so the unique
+// index treats differently-cased spellings as the same address. An empty
+// string normalizes to nil, which clears the email.
+func normalizeEmail(raw string) (*string, bool) {
+	email := strings.ToLower(strings.TrimSpace(raw))
+	if email == "" {
+		return nil, true
+	}
+	if len(email) > maxEmailLength {
+		return nil, false
+	}
+	// Reject display-name forms such as "Alice <alice@example.com>"
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return nil, false
+	}
+	return &email, true
+}
+
+func normalizeDisplayName(raw string) (string, bool) {
+	name := strings.TrimSpace(raw)
+	return name, len([]rune(name)) <= maxDisplayNameLength
+}
+
+// ensureEmailAvailable fails with errEmailTaken when another user already
+// has the address
+func ensureEmailAvailable(tx *gorm.DB, email *string, userID uint) error {
+	if email == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.User{}).
+		Where("email = ? AND id <> ?", *email, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errEmailTaken
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/middleware"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// callHandler runs handler through the error middleware, as the given user
+// when one is passed, and returns the response and its problem code
+func callHandler(t *testing.T, handler gin.HandlerFunc, method, body string, user *models.User) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Handle(method, "/", func(c *gin.Context) {
+		if user != nil {
+			c.Set("user", *user)
+		}
+		handler(c)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var p struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &p)
+	return w, p.Code
+}
+
+// useTestDB points the handlers at a fresh database for the test
+func useTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := newTestDB(t)
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	return db
+}
+
+// raceOn runs and commits sql once, just before the first create or update
+// of the users table, as if a concurrent request had done so after the
+// handler's own checks
+func raceOn(t *testing.T, db *gorm.DB, operation, sql string, args ...interface{}) {
+	t.Helper()
+	done := false
+	race := func(tx *gorm.DB) {
+		if done || tx.Statement.Table != "users" {
+			return
+		}
+		done = true
+		if err := tx.Session(&gorm.Session{NewDB: true}).Exec(sql, args...).Error; err != nil {
+			t.Errorf("racing insert: %v", err)
+		}
+	}
+
+	var err error
+	switch operation {
+	case "create":
+		err = db.Callback().Create().Before("gorm:begin_transaction").Register("test:race", race)
+	case "update":
+		err = db.Callback().Update().Before("gorm:begin_transaction").Register("test:race", race)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUniqueViolationsAreConflicts(t *testing.T) {
+	const insertUser = "INSERT INTO users (username, password, email) VALUES (?, 'x', ?)"
+
+	t.Run("profile email", func(t *testing.T) {
+		db := useTestDB(t)
+		user := models.User{Username: "alice", Password: "x"}
+		mustCreate(t, db, &user)
+		raceOn(t, db, "update", insertUser, "bob", "shared@example.com")
+
+		w, code := callHandler(t, UpdateProfile, http.MethodPatch, `{"email":"Shared@example.com"}`, &user)
+		if w.Code != http.StatusConflict || code != problem.CodeEmailTaken {
+			t.Errorf("status %d code %s, want 409 %s: %s", w.Code, code, problem.CodeEmailTaken, w.Body)
+		}
+	})
+
+	t.Run("signup email", func(t *testing.T) {
+		db := useTestDB(t)
+		raceOn(t, db, "create", insertUser, "bob", "shared@example.com")
+
+		w, code := callHandler(t, CreateUser, http.MethodPost,
+			`{"username":"alice","password":"Correct-Horse-42","email":"shared@example.com"}`, nil)
+		if w.Code != http.StatusConflict || code != problem.CodeEmailTaken {
+			t.Errorf("status %d code %s, want 409 %s: %s", w.Code, code, problem.CodeEmailTaken, w.Body)
+		}
+	})
+
+	t.Run("signup username", func(t *testing.T) {
+		db := useTestDB(t)
+		raceOn(t, db, "create", insertUser, "alice", nil)
+
+		w, code := callHandler(t, CreateUser, http.MethodPost,
+			`{"username":"alice","password":"Correct-Horse-42"}`, nil)
+		if w.Code != http.StatusConflict || code != problem.CodeUsernameTaken {
+			t.Errorf("status %d code %s, want 409 %s: %s", w.Code, code, problem.CodeUsernameTaken, w.Body)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"shopping-cart-backend/models"
+	"time"
+)
+
+// Response DTOs. Handlers never marshal models directly, so a field added to
+// a model (a password hash, a token, lockout state) is not exposed until it
+// is deliberately added here.
+
+// ItemResponse is a catalog item
+type ItemResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Price       int64     `json:"price"` // minor units
+	Currency    string    `json:"currency"`
+	Stock       int       `json:"stock"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CartItemResponse is one cart line. ItemName, UnitPrice and Currency are
+// snapshots taken when the item was added; Item, when loaded, is the item as
+// it is in the catalog now.
+type CartItemResponse struct {
+	CartID    uint          `json:"cart_id"`
+	ItemID    uint          `json:"item_id"`
+	ItemName  string        `json:"item_name"`
+	Quantity  int           `json:"quantity"`
+	UnitPrice int64         `json:"unit_price"`
+	LineTotal int64         `json:"line_total"`
+	Currency  string        `json:"currency"`
+	Item      *ItemResponse `json:"item,omitempty"`
+}
+
+// CartResponse is a cart together with the totals computed from its lines.
+// Items lists the current catalog items and is left out of order responses,
+// whose cart_items carry the snapshots.
+type CartResponse struct {
+	ID        uint               `json:"id"`
+	UserID    uint               `json:"user_id"`
+	Name      string             `json:"name"`
+	Status    string             `json:"status"`
+	CreatedAt time.Time          `json:"created_at"`
+	Items     []ItemResponse     `json:"items,omitempty"`
+	CartItems []CartItemResponse `json:"cart_items"`
+	Subtotal  int64              `json:"subtotal"`
+}
+
+// OrderStatusChangeResponse is one entry of an order's status history
+type OrderStatusChangeResponse struct {
+	FromStatus  string    `json:"from_status"`
+	ToStatus    string    `json:"to_status"`
+	ChangedByID *uint     `json:"changed_by_id"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OrderResponse is a placed order and, when loaded, the cart it was placed
+// from and its status history
+type OrderResponse struct {
+	ID        uint          `json:"id"`
+	CartID    uint          `json:"cart_id"`
+	UserID    uint          `json:"user_id"`
+	Status    string        `json:"status"`
+	Subtotal  int64         `json:"subtotal"`
+	Total     int64         `json:"total"`
+	Currency  string        `json:"currency"`
+	CreatedAt time.Time     `json:"created_at"`
+	Cart      *CartResponse `json:"cart,omitempty"`
+
+	StatusHistory []OrderStatusChangeResponse `json:"status_history,omitempty"`
+}
+
+// OrderSummaryResponse is an order without its cart: the totals, the number
+// of distinct lines and the number of units
+type OrderSummaryResponse struct {
+	ID        uint      `json:"id"`
+	CartID    uint      `json:"cart_id"`
+	Status    string    `json:"status"`
+	Subtotal  int64     `json:"subtotal"`
+	Total     int64     `json:"total"`
+	Currency  string    `json:"currency"`
+	LineCount int       `json:"line_count"`
+	ItemCount int       `json:"item_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newItemResponse(item *models.Item) ItemResponse {
+	return ItemResponse{
+		ID:          item.ID,
+		Name:        item.Name,
+		Description: item.Description,
+		Price:       item.Price,
+		Currency:    item.Currency,
+		Stock:       item.Stock,
+		Status:      item.Status,
+		CreatedAt:   item.CreatedAt,
+		UpdatedAt:   item.UpdatedAt,
+	}
+}
+
+func newItemResponses(items []models.Item) []ItemResponse {
+	response := make([]ItemResponse, 0, len(items))
+	for i := range items {
+		response = append(response, newItemResponse(&items[i]))
+	}
+	return response
+}
+
+func newCartResponse(cart *models.Cart) CartResponse {
+	response := CartResponse{
+		ID:        cart.ID,
+		UserID:    cart.UserID,
+		Name:      cart.Name,
+		Status:    cart.Status,
+		CreatedAt: cart.CreatedAt,
+		Items:     newItemResponses(cart.Items),
+		CartItems: make([]CartItemResponse, 0, len(cart.CartItems)),
+	}
+	for _, cartItem := range cart.CartItems {
+		line := CartItemResponse{
+			CartID:    cartItem.CartID,
+			ItemID:    cartItem.ItemID,
+			ItemName:  cartItem.ItemName,
+			Quantity:  cartItem.Quantity,
+			UnitPrice: cartItem.UnitPrice,
+			LineTotal: cartItem.LineTotal(),
+			Currency:  cartItem.Currency,
+		}
+		if cartItem.Item.ID != 0 {
+			item := newItemResponse(&cartItem.Item)
+			line.Item = &item
+		}
+		response.CartItems = append(response.CartItems, line)
+		response.Subtotal += cartItem.LineTotal()
+	}
+	return response
+}
+
+func newOrderResponse(order *models.Order) OrderResponse {
+	response := OrderResponse{
+		ID:        order.ID,
+		CartID:    order.CartID,
+		UserID:    order.UserID,
+		Status:    order.Status,
+		Subtotal:  order.Subtotal,
+		Total:     order.Total,
+		Currency:  order.Currency,
+		CreatedAt: order.CreatedAt,
+	}
+	if order.Cart.ID != 0 {
+		cart := newCartResponse(&order.Cart)
+		cart.Items = nil
+		response.Cart = &cart
+	}
+	for _, change := range order.StatusHistory {
+		response.StatusHistory = append(response.StatusHistory, OrderStatusChangeResponse{
+			FromStatus:  change.FromStatus,
+			ToStatus:    change.ToStatus,
+			ChangedByID: change.ChangedByID,
+			Reason:      change.Reason,
+			CreatedAt:   change.CreatedAt,
+		})
+	}
+	return response
+}
+
+func newOrderSummary(order *models.Order) OrderSummaryResponse {
+	return OrderSummaryResponse{
+		ID:        order.ID,
+		CartID:    order.CartID,
+		Status:    order.Status,
+		Subtotal:  order.Subtotal,
+		Total:     order.Total,
+		Currency:  order.Currency,
+		CreatedAt: order.CreatedAt,
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ItemSearchResult is an item matched by full-text search, with its relevance
+// score (higher is better) and the matching text highlighted with <mark> tags
+type ItemSearchResult struct {
+	ItemResponse
+	Score     float64       `json:"score"`
+	Highlight ItemHighlight `json:"highlight"`
+}
+
+// ItemHighlight holds HTML: the item text is escaped and only the <mark>
+// tags around matches are markup, so clients can render it as is
+type ItemHighlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// itemSearchRow is the raw shape scanned from the FTS query
+type itemSearchRow struct {
+	models.Item
+	Rank               float64
+	NameSnippet        string
+	DescriptionSnippet string
+}
+
+// Snippet match delimiters. snippet() returns raw item text, so it marks
+// matches with control characters that highlightHTML turns into tags only
+// after the text has been escaped.
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+var matchTags = strings.NewReplacer(matchStart, "<mark>", matchEnd, "</mark>")
+
+// highlightHTML escapes a snippet and replaces its match delimiters with
+// <mark> tags
+func highlightHTML(snippet string) string {
+	return matchTags.Replace(html.EscapeString(snippet))
+}
+
+// SearchItems handles GET /items/search?q=
+//
+// Every word in q must match the start of a word in the item's name or
+// description. Results are ranked by BM25 with name matches weighted above
+// description matches, and paginated like GET /items. Databases other than
+// SQLite have no FTS5 index and fall back to unranked substring matching.
+func SearchItems(c *gin.Context) {
+	words := searchWords(c.Query("q"))
+	if len(words) == 0 {
+		c.Error(problem.InvalidParameter("q", "Query parameter q is required"))
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	if !database.IsSQLite(database.DB) {
+		searchItemsByLike(c, words, page)
+		return
+	}
+
+	match := ftsQuery(words)
+
+	const from = `FROM items_fts JOIN items ON items.id = items_fts.rowid
+		WHERE items_fts MATCH ? AND items.deleted_at IS NULL`
+
+	var total int64
+	if err := database.DB.Raw("SELECT COUNT(*) "+from, match).Scan(&total).Error; err != nil {
+		c.Error(problem.Internal("Failed to search items", err))
+		return
+	}
+
+	var rows []itemSearchRow
+	err := database.DB.Raw(`SELECT items.*,
+			bm25(items_fts, 10.0, 1.0) AS rank,
+			snippet(items_fts, 0, ?, ?, '…', 10) AS name_snippet,
+			snippet(items_fts, 1, ?, ?, '…', 16) AS description_snippet
+		`+from+` ORDER BY rank, items.id LIMIT ? OFFSET ?`,
+		matchStart, matchEnd, matchStart, matchEnd, match, page.Size, page.Offset()).Scan(&rows).Error
+	if err != nil {
+		c.Error(problem.Internal("Failed to search items", err))
+		return
+	}
+
+	results := make([]ItemSearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, ItemSearchResult{
+			ItemResponse: newItemResponse(&row.Item),
+			Score:        -row.Rank, // bm25 is lower-is-better
+			Highlight: ItemHighlight{
+				Name:        highlightHTML(row.NameSnippet),
+				Description: highlightHTML(row.DescriptionSnippet),
+			},
+		})
+	}
+
+	setPaginationHeaders(c, page, total)
+	c.JSON(http.StatusOK, results)
+}
+
+// searchItemsByLike is the SearchItems fallback for databases without FTS5.
+// Every word must occur in the name or description; results are ordered by
+// id with a zero score and escaped but unhighlighted text.
+func searchItemsByLike(c *gin.Context, words []string, page Page) {
+	query := database.DB.Model(&models.Item{})
+	for _, word := range words {
+		pattern := likePattern(word)
+		query = query.Where("(LOWER(name) LIKE ? ESCAPE '!' OR LOWER(description) LIKE ? ESCAPE '!')", pattern, pattern)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.Error(problem.Internal("Failed to search items", err))
+		return
+	}
+
+	var items []models.Item
+	if err := query.Order("id").Scopes(page.Scope).Find(&items).Error; err != nil {
+		c.Error(problem.Internal("Failed to search items", err))
+		return
+	}
+
+	results := make([]ItemSearchResult, 0, len(items))
+	for i, item := range items {
+		results = append(results, ItemSearchResult{
+			ItemResponse: newItemResponse(&items[i]),
+			Highlight: ItemHighlight{
+				Name:        html.EscapeString(item.Name),
+				Description: html.EscapeString(item.Description),
+			},
+		})
+	}
+
+	setPaginationHeaders(c, page, total)
+	c.JSON(http.StatusOK, results)
+}
+
+// searchWords splits free text into the letter/number runs it contains
+func searchWords(q string) []string {
+	return strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// ftsQuery turns words into an FTS5 query where every word is a quoted
+// prefix term, so user input can never inject FTS syntax
+func ftsQuery(words []string) string {
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSearchHighlightsAreEscaped(t *testing.T) {
+	db := newTestDB(t)
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+
+	mustCreate(t, db, &models.Item{
+		Name:        "<img src=x onerror=alert(1)>",
+		Description: `a "quoted" & <b>bold</b> img`,
+		Currency:    "INR",
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/items/search?q=img", nil)
+	SearchItems(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var results []ItemSearchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	want := ItemHighlight{
+		Name:        "&lt;<mark>img</mark> src=x onerror=alert(1)&gt;",
+		Description: "a &#34;quoted&#34; &amp; &lt;b&gt;bold&lt;/b&gt; <mark>img</mark>",
+	}
+	if results[0].Highlight != want {
+		t.Errorf("highlight = %+v, want %+v", results[0].Highlight, want)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/middleware"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxDeviceLabelLength bounds device labels, which may come from user agents
+const maxDeviceLabelLength = 100
+
+// SessionResponse describes one of the user's logged-in devices
+type SessionResponse struct {
+	ID          string    `json:"id"`
+	DeviceLabel string    `json:"device_label"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	Current     bool      `json:"current"` // the session making this request
+}
+
+// GetSessions handles GET /sessions
+//
+// Lists the user's live sessions, most recently active first. Sessions idle
+// for longer than a refresh token lives can no longer be resumed and are
+// left out.
+func GetSessions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	currentID := c.GetString("session_id")
+
+	var sessions []models.Session
+	if err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND last_seen_at > ?",
+			userID, time.Now().Add(-middleware.RefreshTokenTTL())).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.Error(problem.Internal("Failed to fetch sessions", err))
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:          session.ID,
+			DeviceLabel: session.DeviceLabel,
+			IP:          session.IP,
+			UserAgent:   session.UserAgent,
+			CreatedAt:   session.CreatedAt,
+			LastSeenAt:  session.LastSeenAt,
+			Current:     session.ID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteSession handles DELETE /sessions/:id (log out one device)
+func DeleteSession(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	sessionID := c.Param("id")
+
+	// Other users' sessions are reported as not found
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		First(&session).Error; err != nil {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeSessionNotFound, "Session not found"))
+		return
+	}
+
+	if err := revokeSessions(database.DB, "id = ?", session.ID); err != nil {
+		c.Error(problem.Internal("Failed to revoke session", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Session revoked successfully",
+		"session_id": session.ID,
+	})
+}
+
+// Logout handles POST /users/logout (end the current session)
+func Logout(c *gin.Context) {
+	if err := logout(c, "id = ?", c.GetString("session_id")); err != nil {
+		c.Error(problem.Internal("Failed to log out", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll handles POST /users/logout-all (end every session of the user)
+func LogoutAll(c *gin.Context) {
+	if err := logout(c, "user_id = ?", c.GetUint("user_id")); err != nil {
+		c.Error(problem.Internal("Failed to log out", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions successfully"})
+}
+
+// logout revokes the matching sessions and puts the access token of the
+// current request on the revocation list, so it stops working immediately
+func logout(c *gin.Context, query interface{}, args ...interface{}) error {
+	claims := c.MustGet("claims").(*middleware.Claims)
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeSessions(tx, query, args...); err != nil {
+			return err
+		}
+		return middleware.RevokeToken(tx, claims)
+	})
+}
+
+// deviceLabel picks a display name for a new session: the label the client
+// sent, else its user agent, else a generic name
+func deviceLabel(label, userAgent string) string {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = strings.TrimSpace(userAgent)
+	}
+	if label == "" {
+		label = "Unknown device"
+	}
+	if runes := []rune(label); len(runes) > maxDeviceLabelLength {
+		label = string(runes[:maxDeviceLabelLength])
+	}
+	return label
+}
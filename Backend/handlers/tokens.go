@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/middleware"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+var (
+	errRefreshTokenInvalid = errors.New("refresh token invalid or expired")
+	errRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// RefreshToken handles POST /users/refresh
+//
+// The refresh token is single-use: it is exchanged for a new access token
+// and a new refresh token for the same session. Presenting a token that was
+// already exchanged means it was copied, so the session and every refresh
+// token issued for it are revoked and the device has to log in again.
+func RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	var stored models.RefreshToken
+	var response UserResponse
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ?", middleware.HashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errRefreshTokenInvalid
+			}
+			return err
+		}
+		if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return errRefreshTokenInvalid
+		}
+
+		// Mark the token used; a concurrent or repeated exchange matches no rows
+		result := tx.Model(&stored).Where("used_at IS NULL").Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		var session models.Session
+		if err := tx.First(&session, "id = ?", stored.FamilyID).Error; err != nil || session.RevokedAt != nil {
+			return errRefreshTokenInvalid
+		}
+		if err := tx.Model(&session).Update("last_seen_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, stored.UserID).Error; err != nil {
+			return errRefreshTokenInvalid
+		}
+
+		var err error
+		response, err = issueTokens(tx, &user, session.ID)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errRefreshTokenReused):
+		if err := revokeSessions(database.DB, "id = ?", stored.FamilyID); err != nil {
+			c.Error(problem.Internal("Failed to revoke tokens", err))
+			return
+		}
+		c.Error(problem.New(http.StatusUnauthorized, problem.CodeRefreshTokenReused, "Refresh token reuse detected; please log in again"))
+		return
+	case errors.Is(err, errRefreshTokenInvalid):
+		c.Error(problem.New(http.StatusUnauthorized, problem.CodeRefreshTokenInvalid, "Invalid refresh token"))
+		return
+	case err != nil:
+		c.Error(problem.Internal("Failed to refresh token", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// issueTokens signs a new access token for the user's session and adds a
+// new refresh token to the session's token family
+func issueTokens(tx *gorm.DB, user *models.User, sessionID string) (UserResponse, error) {
+	token, err := middleware.GenerateJWT(user.ID, sessionID)
+	if err != nil {
+		return UserResponse{}, err
+	}
+
+	refreshToken, err := middleware.RandomToken(32)
+	if err != nil {
+		return UserResponse{}, err
+	}
+	if err := tx.Create(&models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: middleware.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(middleware.RefreshTokenTTL()),
+	}).Error; err != nil {
+		return UserResponse{}, err
+	}
+
+	return UserResponse{
+		ID:           user.ID,
+		Username:     user.Username,
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(middleware.AccessTokenTTL() / time.Second),
+		SessionID:    sessionID,
+	}, nil
+}
+
+// revokeSessions revokes the live sessions matching the given conditions
+// together with every refresh token issued for them
+func revokeSessions(db *gorm.DB, query interface{}, args ...interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var sessionIDs []string
+		if err := tx.Model(&models.Session{}).Where(query, args...).
+			Where("revoked_at IS NULL").Pluck("id", &sessionIDs).Error; err != nil {
+			return err
+		}
+		if len(sessionIDs) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.Session{}).Where("id IN ?", sessionIDs).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id IN ? AND revoked_at IS NULL", sessionIDs).
+			Update("revoked_at", now).Error
+	})
+}
+
+// GetJWKS handles GET /.well-known/jwks.json, publishing the public keys
+// that verify our access tokens
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": middleware.PublicJWKS()})
+}
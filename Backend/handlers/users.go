@@ -1,61 +1,104 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"shopping-cart-backend/database"
 	"shopping-cart-backend/middleware"
 	"shopping-cart-backend/models"
-	
+	"shopping-cart-backend/passwords"
+	"shopping-cart-backend/problem"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type CreateUserRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username    string `json:"username" binding:"required,username"`
+	Password    string `json:"password" binding:"required"` // see passwords.Check
+	Email       string `json:"email" binding:"omitempty,max=254"`
+	DisplayName string `json:"display_name" binding:"max=100"`
 }
 
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username    string `json:"username" binding:"required"`
+	Password    string `json:"password" binding:"required"`
+	DeviceLabel string `json:"device_label"` // e.g. "Work laptop"; defaults to the user agent
 }
 
 type UserResponse struct {
-	ID       uint   `json:"id"`
-	Username string `json:"username"`
-	Token    string `json:"token"`
+	ID           uint   `json:"id"`
+	Username     string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+	SessionID    string `json:"session_id"`
 }
 
 // CreateUser handles POST /users
 func CreateUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(problem.Binding(err))
+		return
+	}
+
+	if err := passwords.Check(req.Password, req.Username); err != nil {
+		c.Error(problem.New(http.StatusBadRequest, problem.CodeWeakPassword, err.Error()))
+		return
+	}
+
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		c.Error(problem.Invalid("email", "email", "email must be a valid email address"))
+		return
+	}
+	displayName, ok := normalizeDisplayName(req.DisplayName)
+	if !ok {
+		c.Error(problem.Invalid("display_name", "max", "display_name must be at most 100 characters long"))
 		return
 	}
 
 	// Check if user already exists
 	var existingUser models.User
 	if err := database.DB.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		c.Error(problem.New(http.StatusConflict, problem.CodeUsernameTaken, "Username already exists"))
+		return
+	}
+	if err := ensureEmailAvailable(database.DB, email, 0); err != nil {
+		if errors.Is(err, errEmailTaken) {
+			c.Error(problem.New(http.StatusConflict, problem.CodeEmailTaken, "Email already in use"))
+			return
+		}
+		c.Error(problem.Internal("Failed to create user", err))
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		c.Error(problem.Internal("Failed to hash password", err))
 		return
 	}
 
 	// Create user
 	user := models.User{
-		Username: req.Username,
-		Password: string(hashedPassword),
+		Username:    req.Username,
+		Password:    string(hashedPassword),
+		Email:       email,
+		DisplayName: displayName,
 	}
 
 	if err := database.DB.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		// The checks above can race with another signup; the unique indexes
+		// have the final say
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.Error(duplicateUserProblem(database.DB, req.Username))
+			return
+		}
+		c.Error(problem.Internal("Failed to create user", err))
 		return
 	}
 
@@ -70,51 +113,112 @@ func CreateUser(c *gin.Context) {
 func Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(problem.Binding(err))
 		return
 	}
 
 	// Find user
 	var user models.User
 	if err := database.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		// Unknown usernames fail and lock out just like wrong passwords, so
+		// the response does not reveal whether the account exists
+		now := time.Now()
+		if wait := unknownLogins.lockedFor(req.Username, now); wait > 0 {
+			accountLocked(c, wait)
+			return
+		}
+		bcrypt.CompareHashAndPassword(unknownUserHash, []byte(req.Password))
+		if wait := unknownLogins.recordFailure(req.Username, now); wait > 0 {
+			accountLocked(c, wait)
+			return
+		}
+		c.Error(problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid credentials"))
+		return
+	}
+
+	// Locked accounts are refused before the password is even checked, so
+	// guessing cannot continue during the lockout
+	if wait := lockedFor(&user, time.Now()); wait > 0 {
+		accountLocked(c, wait)
 		return
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		wait, err := recordFailedLogin(database.DB, &user)
+		if err != nil {
+			c.Error(problem.Internal("Failed to record login attempt", err))
+			return
+		}
+		if wait > 0 {
+			accountLocked(c, wait)
+			return
+		}
+		c.Error(problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid credentials"))
 		return
 	}
 
-	// Generate token
-	token, err := middleware.GenerateJWT(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	if err := resetFailedLogins(database.DB, &user); err != nil {
+		c.Error(problem.Internal("Failed to record login attempt", err))
 		return
 	}
 
-	// Update user with new token (single session)
-	user.Token = token
-	if err := database.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save token"})
+	sessionID, err := middleware.RandomToken(16)
+	if err != nil {
+		c.Error(problem.Internal("Failed to generate token", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Token:    token,
+	// Each login is a new session; other devices stay logged in
+	userAgent := c.Request.UserAgent()
+	session := models.Session{
+		ID:          sessionID,
+		UserID:      user.ID,
+		DeviceLabel: deviceLabel(req.DeviceLabel, userAgent),
+		IP:          c.ClientIP(),
+		UserAgent:   userAgent,
+		LastSeenAt:  time.Now(),
+	}
+
+	var response UserResponse
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		response, err = issueTokens(tx, &user, session.ID)
+		return err
 	})
+	if err != nil {
+		c.Error(problem.Internal("Failed to generate token", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// duplicateUserProblem reports which unique field a new user collided with.
+// Only username and email are unique, so if the username is free it was the
+// email.
+func duplicateUserProblem(db *gorm.DB, username string) *problem.Error {
+	var count int64
+	db.Model(&models.User{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		return problem.New(http.StatusConflict, problem.CodeUsernameTaken, "Username already exists")
+	}
+	return problem.New(http.StatusConflict, problem.CodeEmailTaken, "Email already in use")
 }
 
 // GetUsers handles GET /users
 func GetUsers(c *gin.Context) {
 	var users []models.User
 	if err := database.DB.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		c.Error(problem.Internal("Failed to fetch users", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	response := make([]ProfileResponse, 0, len(users))
+	for i := range users {
+		response = append(response, profileResponse(&users[i]))
+	}
+	c.JSON(http.StatusOK, response)
 }
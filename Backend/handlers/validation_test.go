@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"shopping-cart-backend/problem"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindProblem binds body into req the way the handlers do and returns the
+// resulting API error, or nil when the body is valid
+func bindProblem(t *testing.T, req interface{}, body string) *problem.Error {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if err := c.ShouldBindJSON(req); err != nil {
+		return problem.Binding(err)
+	}
+	return nil
+}
+
+func TestRequestValidation(t *testing.T) {
+	const orderStatuses = "pending, paid, fulfilled, shipped, delivered, cancelled, refunded"
+	const usernameRule = "username must be 3-32 letters, digits, '.', '_' or '-', starting with a letter or digit"
+
+	cases := []struct {
+		name    string
+		request func() interface{}
+		body    string
+		field   string // empty when the body is valid
+		message string
+	}{
+		// CreateUserRequest
+		{"username valid", func() interface{} { return &CreateUserRequest{} },
+			`{"username":"alice.b-1","password":"x"}`, "", ""},
+		{"username missing", func() interface{} { return &CreateUserRequest{} },
+			`{"password":"x"}`, "username", "username is required"},
+		{"username too short", func() interface{} { return &CreateUserRequest{} },
+			`{"username":"ab","password":"x"}`, "username", usernameRule},
+		{"username bad characters", func() interface{} { return &CreateUserRequest{} },
+			`{"username":"bad name","password":"x"}`, "username", usernameRule},
+		{"username leading punctuation", func() interface{} { return &CreateUserRequest{} },
+			`{"username":"_alice","password":"x"}`, "username", usernameRule},
+
+		// CreateItemRequest
+		{"item valid", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"Pen","price":0,"stock":1000000,"currency":"usd","status":"unavailable"}`, "", ""},
+		{"item status unknown", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"Pen","status":"sold"}`, "status", "status must be one of: available, unavailable"},
+		{"item currency too short", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"Pen","currency":"US"}`, "currency", "currency must be a 3-letter ISO 4217 currency code"},
+		{"item currency not letters", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"Pen","currency":"U5D"}`, "currency", "currency must be a 3-letter ISO 4217 currency code"},
+		{"item price negative", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"Pen","price":-1}`, "price", "price must be at least 0"},
+		{"item price too high", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"Pen","price":1000000001}`, "price", "price must be at most 1000000000"},
+		{"item stock negative", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"Pen","stock":-1}`, "stock", "stock must be at least 0"},
+		{"item stock too high", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"Pen","stock":1000001}`, "stock", "stock must be at most 1000000"},
+		{"item name not a string", func() interface{} { return &CreateItemRequest{} },
+			`{"name":5}`, "name", "name must be a string"},
+		{"item name too long", func() interface{} { return &CreateItemRequest{} },
+			`{"name":"` + strings.Repeat("x", 101) + `"}`, "name", "name must be at most 100 characters long"},
+
+		// UpdateItemRequest
+		{"item patch empty", func() interface{} { return &UpdateItemRequest{} },
+			`{}`, "", ""},
+		{"item patch price negative", func() interface{} { return &UpdateItemRequest{} },
+			`{"price":-5}`, "price", "price must be at least 0"},
+		{"item patch stock too high", func() interface{} { return &UpdateItemRequest{} },
+			`{"stock":2000000}`, "stock", "stock must be at most 1000000"},
+		{"item patch status unknown", func() interface{} { return &UpdateItemRequest{} },
+			`{"status":"gone"}`, "status", "status must be one of: available, unavailable"},
+
+		// AddItemToCartRequest and UpdateCartItemRequest
+		{"add to cart default quantity", func() interface{} { return &AddItemToCartRequest{} },
+			`{"item_id":1}`, "", ""},
+		{"add to cart negative quantity", func() interface{} { return &AddItemToCartRequest{} },
+			`{"item_id":1,"quantity":-1}`, "quantity", "quantity must be at least 1"},
+		{"add to cart item missing", func() interface{} { return &AddItemToCartRequest{} },
+			`{"quantity":2}`, "item_id", "item_id is required"},
+		{"add to cart item id not a number", func() interface{} { return &AddItemToCartRequest{} },
+			`{"item_id":"one"}`, "item_id", "item_id must be a positive integer"},
+		{"add to cart negative item id", func() interface{} { return &AddItemToCartRequest{} },
+			`{"item_id":-1}`, "item_id", "item_id must be a positive integer"},
+		{"cart quantity not a number", func() interface{} { return &UpdateCartItemRequest{} },
+			`{"quantity":"2"}`, "quantity", "quantity must be an integer"},
+		{"cart quantity zero removes", func() interface{} { return &UpdateCartItemRequest{} },
+			`{"quantity":0}`, "", ""},
+		{"cart quantity negative", func() interface{} { return &UpdateCartItemRequest{} },
+			`{"quantity":-1}`, "quantity", "quantity must be at least 0"},
+		{"cart quantity missing", func() interface{} { return &UpdateCartItemRequest{} },
+			`{}`, "quantity", "quantity is required"},
+
+		// UpdateOrderStatusRequest
+		{"order status valid", func() interface{} { return &UpdateOrderStatusRequest{} },
+			`{"status":"shipped"}`, "", ""},
+		{"order status unknown", func() interface{} { return &UpdateOrderStatusRequest{} },
+			`{"status":"lost"}`, "status", "status must be one of: " + orderStatuses},
+		{"order status reason too long", func() interface{} { return &UpdateOrderStatusRequest{} },
+			`{"status":"paid","reason":"` + strings.Repeat("x", 501) + `"}`, "reason", "reason must be at most 500 characters long"},
+
+		// ChangePasswordRequest and PasswordResetRequest
+		{"new password differs", func() interface{} { return &ChangePasswordRequest{} },
+			`{"current_password":"a","new_password":"b"}`, "", ""},
+		{"new password same as current", func() interface{} { return &ChangePasswordRequest{} },
+			`{"current_password":"a","new_password":"a"}`, "new_password", "new_password must differ from current_password"},
+		{"reset needs username or email", func() interface{} { return &PasswordResetRequest{} },
+			`{}`, "username", "username is required when email is not given"},
+
+		// UpdateProfileRequest
+		{"display name too long", func() interface{} { return &UpdateProfileRequest{} },
+			`{"display_name":"` + strings.Repeat("x", 101) + `"}`, "display_name", "display_name must be at most 100 characters long"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bindProblem(t, tc.request(), tc.body)
+			if tc.field == "" {
+				if e != nil {
+					t.Fatalf("unexpected error: %v (%+v)", e, e.Fields)
+				}
+				return
+			}
+			if e == nil {
+				t.Fatal("body was accepted, want a validation error")
+			}
+			if e.Status != http.StatusBadRequest || e.Code != problem.CodeValidationFailed {
+				t.Errorf("status %d code %s, want 400 %s", e.Status, e.Code, problem.CodeValidationFailed)
+			}
+
+			for _, fe := range e.Fields {
+				if fe.Field == tc.field {
+					if fe.Message != tc.message {
+						t.Errorf("message = %q, want %q", fe.Message, tc.message)
+					}
+					return
+				}
+			}
+			t.Errorf("no error for field %q in %+v", tc.field, e.Fields)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/handlers"
+	"shopping-cart-backend/middleware"
+	"time"
+)
+
+// janitorInterval is how often expired bookkeeping rows are purged
+const janitorInterval = time.Hour
+
+// startJanitor purges expired rows in the background, once at startup and
+// then every interval
+func startJanitor(interval time.Duration) {
+	go func() {
+		for {
+			purgeExpired()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func purgeExpired() {
+	if n, err := middleware.PurgeExpiredRevocations(database.DB); err != nil {
+		log.Println("Failed to purge expired token revocations:", err)
+	} else if n > 0 {
+		log.Printf("Purged %d expired token revocations", n)
+	}
+
+	if n, err := handlers.PurgeExpiredPasswordResets(database.DB); err != nil {
+		log.Println("Failed to purge expired password reset tokens:", err)
+	} else if n > 0 {
+		log.Printf("Purged %d expired password reset tokens", n)
+	}
+
+	if n, err := middleware.PurgeExpiredIdempotencyKeys(database.DB); err != nil {
+		log.Println("Failed to purge expired idempotency keys:", err)
+	} else if n > 0 {
+		log.Printf("Purged %d expired idempotency keys", n)
+	}
+}
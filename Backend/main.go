@@ -1,26 +1,70 @@
 package main
 
 import (
+	"log"
+	"net/http"
 	"os"
 	"shopping-cart-backend/database"
 	"shopping-cart-backend/handlers"
 	"shopping-cart-backend/middleware"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/notifier"
+	"shopping-cart-backend/passwords"
+	"shopping-cart-backend/problem"
+	"strings"
 	
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// Run a CLI subcommand instead of the server when one is given
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	// Connect to database
 	database.Connect()
+	if err := passwords.LoadPolicy(); err != nil {
+		log.Fatal("Invalid password policy: ", err)
+	}
+	if err := notifier.Configure(); err != nil {
+		log.Fatal("Invalid notifier configuration: ", err)
+	}
+	bootstrapAdmin()
+	if err := middleware.LoadKeys(); err != nil {
+		log.Fatal("Failed to load JWT signing keys: ", err)
+	}
+	startJanitor(janitorInterval)
 
 	// Set Gin mode based on environment
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	r := newRouter()
+
+	// Start server
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8081"
+	}
+	r.Run(":" + port)
+}
+
+// newRouter builds the router with every middleware and route registered
+func newRouter() *gin.Engine {
 	// Create Gin router
 	r := gin.Default()
 
+	// The client IP keys the login rate limit, so X-Forwarded-For is only
+	// believed from the proxies listed in TRUSTED_PROXIES (comma-separated
+	// IPs or CIDRs). By default no proxy is trusted.
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES: ", err)
+	}
+
+	r.Use(middleware.RequestID(), middleware.ErrorHandler())
+
 	// CORS middleware with environment-based origin
 	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
 	if allowedOrigins == "" {
@@ -29,8 +73,9 @@ func main() {
 
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", allowedOrigins)
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "Link, X-Total-Count, X-Page, X-Per-Page, X-Total-Pages, X-Next-Page, Retry-After, X-Request-ID, Idempotent-Replayed")
 		
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -42,28 +87,66 @@ func main() {
 
 	// Public routes
 	r.POST("/users", handlers.CreateUser)
-	r.GET("/users", handlers.GetUsers)
-	r.POST("/users/login", handlers.Login)
+	r.POST("/users/login", middleware.LoginRateLimit(), handlers.Login)
+	r.POST("/users/refresh", handlers.RefreshToken)
+	r.POST("/users/password-reset", middleware.LoginRateLimit(), handlers.RequestPasswordReset)
+	r.POST("/users/password-reset/confirm", middleware.LoginRateLimit(), handlers.ConfirmPasswordReset)
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
 	
-	r.POST("/items", handlers.CreateItem)
 	r.GET("/items", handlers.GetItems)
+	r.GET("/items/search", handlers.SearchItems)
+	r.GET("/items/:id", handlers.GetItem)
 
 	// Protected routes (require authentication)
 	protected := r.Group("/")
 	protected.Use(middleware.AuthMiddleware())
 	{
-		protected.POST("/carts", handlers.CreateCart)
+		protected.POST("/carts", middleware.Idempotency(), handlers.CreateCart)
 		protected.GET("/carts", handlers.GetCart)
+		protected.PUT("/carts/:itemId", handlers.UpdateCartItem)
 		protected.DELETE("/carts/:itemId", handlers.RemoveFromCart)
 		
-		protected.POST("/orders", handlers.CreateOrder)
+		protected.POST("/orders", middleware.Idempotency(), handlers.CreateOrder)
 		protected.GET("/orders", handlers.GetOrders)
+		protected.GET("/orders/:id", handlers.GetOrder)
+		protected.POST("/orders/:id/cancel", handlers.CancelOrder)
+		
+		protected.POST("/users/logout", handlers.Logout)
+		protected.POST("/users/logout-all", handlers.LogoutAll)
+		protected.GET("/users/me", handlers.GetProfile)
+		protected.PATCH("/users/me", handlers.UpdateProfile)
+		protected.PUT("/users/me/password", handlers.ChangePassword)
+		protected.GET("/sessions", handlers.GetSessions)
+		protected.DELETE("/sessions/:id", handlers.DeleteSession)
 	}
 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8081"
+	// Admin routes (require the admin role)
+	admin := protected.Group("/")
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users", handlers.GetUsers)
+		
+		admin.POST("/items", handlers.CreateItem)
+		admin.PUT("/items/:id", handlers.ReplaceItem)
+		admin.PATCH("/items/:id", handlers.UpdateItem)
+		admin.DELETE("/items/:id", handlers.DeleteItem)
+		admin.PUT("/orders/:id/status", handlers.UpdateOrderStatus)
 	}
-	r.Run(":" + port)
+
+	r.NoRoute(func(c *gin.Context) {
+		c.Error(problem.New(http.StatusNotFound, problem.CodeNotFound, "Route not found"))
+	})
+
+	return r
+}
+
+// trustedProxies parses TRUSTED_PROXIES; empty means trust none
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
 }
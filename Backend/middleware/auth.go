@@ -1,76 +1,175 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
-	"strings"
 	"shopping-cart-backend/database"
 	"shopping-cart-backend/models"
-	
+	"shopping-cart-backend/problem"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key") // In production, use environment variable
+// Token settings, overridable with JWT_ISSUER, JWT_AUDIENCE, JWT_ACCESS_TTL
+// and REFRESH_TOKEN_TTL (durations such as "15m" or "720h")
+var (
+	jwtIssuer       = EnvOr("JWT_ISSUER", "shopping-cart-backend")
+	jwtAudience     = EnvOr("JWT_AUDIENCE", "shopping-cart-api")
+	accessTokenTTL  = EnvDurationOr("JWT_ACCESS_TTL", 15*time.Minute)
+	refreshTokenTTL = EnvDurationOr("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+)
+
+// Claims are the claims carried by an access token
+type Claims struct {
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// lastSeenInterval limits how often a session's last_seen_at is written
+const lastSeenInterval = time.Minute
+
+// AccessTokenTTL is how long a newly issued access token stays valid
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
+// RefreshTokenTTL is how long a newly issued refresh token stays valid
+func RefreshTokenTTL() time.Duration {
+	return refreshTokenTTL
+}
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			Abort(c, problem.New(http.StatusUnauthorized, problem.CodeAuthRequired, "Authorization header required"))
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
-			c.Abort()
+			Abort(c, problem.New(http.StatusUnauthorized, problem.CodeAuthRequired, "Bearer token required"))
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
+		claims, err := ParseAccessToken(tokenString)
+		if err != nil {
+			Abort(c, problem.New(http.StatusUnauthorized, problem.CodeTokenInvalid, "Invalid token"))
+			return
+		}
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		revoked, err := IsTokenRevoked(database.DB, claims.ID)
+		if err != nil {
+			Abort(c, problem.Internal("Failed to verify token", err))
+			return
+		}
+		if revoked {
+			Abort(c, problem.New(http.StatusUnauthorized, problem.CodeTokenRevoked, "Token revoked"))
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+		userID := claims.UserID
+
+		// Verify the session is live and belongs to the token's user
+		var session models.Session
+		if err := database.DB.First(&session, "id = ?", claims.SessionID).Error; err != nil ||
+			session.UserID != userID || session.RevokedAt != nil {
+			Abort(c, problem.New(http.StatusUnauthorized, problem.CodeSessionRevoked, "Session expired or revoked"))
 			return
 		}
 
-		userID := uint(claims["user_id"].(float64))
-		
-		// Verify user exists and token matches
+		// Verify user exists
 		var user models.User
 		if err := database.DB.First(&user, userID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-			c.Abort()
+			Abort(c, problem.New(http.StatusUnauthorized, problem.CodeTokenInvalid, "User not found"))
 			return
 		}
 
-		if user.Token != tokenString {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token mismatch"})
-			c.Abort()
-			return
+		if now := time.Now(); now.Sub(session.LastSeenAt) > lastSeenInterval {
+			database.DB.Model(&session).Updates(map[string]interface{}{
+				"last_seen_at": now,
+				"ip":           c.ClientIP(),
+			})
 		}
 
 		c.Set("user_id", userID)
 		c.Set("user", user)
+		c.Set("session_id", session.ID)
+		c.Set("claims", claims)
 		c.Next()
 	}
 }
 
-func GenerateJWT(userID uint) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
+// ParseAccessToken verifies an access token's signature against the key
+// named by its kid header and its exp, iat, iss and aud claims, and requires
+// the jti, user_id and sid claims
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	if keyring == nil {
+		return nil, errKeysNotLoaded
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyring.keyfunc,
+		jwt.WithValidMethods(keyring.algorithms()),
+		jwt.WithIssuer(jwtIssuer),
+		jwt.WithAudience(jwtAudience),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.ExpiresAt == nil || claims.ID == "" || claims.UserID == 0 || claims.SessionID == "" {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// GenerateJWT issues a short-lived access token for the user's session
+func GenerateJWT(userID uint, sessionID string) (string, error) {
+	if keyring == nil {
+		return "", errKeysNotLoaded
+	}
+
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return keyring.sign(Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Issuer:    jwtIssuer,
+			Audience:  jwt.ClaimStrings{jwtAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+		},
 	})
+}
+
+// RandomToken returns n cryptographically random bytes, hex encoded
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
 
-	return token.SignedString(jwtSecret)
+// HashToken returns the SHA-256 hex digest stored in place of an opaque
+// token. The tokens are random enough that a fast hash is sufficient.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvOr returns the environment variable key, or fallback when it is unset
+func EnvOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// EnvIntOr returns the environment variable key as a positive integer, or
+// fallback when it is unset or not one
+func EnvIntOr(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// EnvDurationOr returns the environment variable key as a positive duration
+// such as "15m", or fallback when it is unset or not one
+func EnvDurationOr(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"shopping-cart-backend/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits client-supplied IDs to something safe to log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tags every request with an ID, reusing the client's X-Request-ID
+// when it sends a sensible one, and echoes it in the response so a report
+// can be matched to the server log
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id, _ = RandomToken(8)
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// ErrorHandler renders the last error a handler recorded with c.Error as
+// application/problem+json. Errors that are not *problem.Error become a
+// generic 500 so internal details never reach the client; their cause is
+// logged with the request ID.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		e := problem.As(c.Errors.Last().Err)
+		requestID := c.GetString("request_id")
+		if e.Status >= http.StatusInternalServerError {
+			log.Printf("[%s] %s %s: %v", requestID, c.Request.Method, c.Request.URL.Path, e)
+		}
+
+		// c.JSON keeps a Content-Type that is already set
+		c.Header("Content-Type", problem.ContentType)
+		c.JSON(e.Status, e.Body(c.Request.URL.Path, requestID))
+	}
+}
+
+// Abort records err for ErrorHandler and stops the handler chain
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeyHeader names the client-chosen key that makes a retried
+// request safe to send again
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyTTL is how long a key is remembered, overridable with
+// IDEMPOTENCY_KEY_TTL. A key whose first request has not finished within
+// idempotencyLockTimeout is assumed abandoned (the server stopped mid-request)
+// and may be claimed again.
+var (
+	idempotencyTTL         = EnvDurationOr("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	idempotencyLockTimeout = time.Minute
+)
+
+var errIdempotencyKeyBusy = errors.New("could not claim idempotency key")
+
+// Idempotency lets clients retry a mutating request without repeating it.
+// The first request with a given Idempotency-Key runs normally and, if it
+// succeeds, its response is stored; a retry with the same key and the same
+// method, path and body gets that response replayed with an
+// Idempotent-Replayed header. Reusing the key for a different request is a
+// 422, and a retry that arrives while the first is still running is a 409.
+//
+// Failed requests are not stored: the key is released so the client can
+// retry once the problem is fixed. Keys are per user, so this must be
+// registered after AuthMiddleware. Requests without the header are not
+// affected.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			Abort(c, problem.InvalidParameter(IdempotencyKeyHeader, "Idempotency-Key must be at most 255 characters long"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			Abort(c, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(c.Request, body)
+
+		record, claimed, err := claimIdempotencyKey(database.DB, c.GetUint("user_id"), key, hash)
+		if err != nil {
+			Abort(c, problem.Internal("Failed to process Idempotency-Key", err))
+			return
+		}
+		if !claimed {
+			switch {
+			case record.RequestHash != hash:
+				Abort(c, problem.New(http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused,
+					"Idempotency-Key was already used for a different request"))
+			case record.StatusCode == 0:
+				SetRetryAfter(c, time.Second)
+				Abort(c, problem.New(http.StatusConflict, problem.CodeIdempotencyKeyInUse,
+					"A request with this Idempotency-Key is still being processed"))
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.StatusCode, record.ContentType, record.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		stored := false
+		defer func() {
+			// Also runs when the handler panics, so the key is not left
+			// claimed until the lock timeout
+			if !stored {
+				if err := database.DB.Delete(&models.IdempotencyRecord{}, record.ID).Error; err != nil {
+					log.Printf("Failed to release idempotency key %d: %v", record.ID, err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if len(c.Errors) > 0 || status < http.StatusOK || status >= http.StatusMultipleChoices {
+			return
+		}
+		err = database.DB.Model(&models.IdempotencyRecord{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"status_code":   status,
+			"content_type":  recorder.Header().Get("Content-Type"),
+			"response_body": recorder.body.Bytes(),
+		}).Error
+		if err != nil {
+			log.Printf("Failed to store idempotent response %d: %v", record.ID, err)
+			return
+		}
+		stored = true
+	}
+}
+
+// claimIdempotencyKey inserts an in-progress record for the key, reporting
+// true when this request now owns it. Otherwise it returns the live record
+// that already holds the key; expired and abandoned records are replaced.
+func claimIdempotencyKey(db *gorm.DB, userID uint, key, hash string) (*models.IdempotencyRecord, bool, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		record := models.IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: hash,
+			ExpiresAt:   time.Now().Add(idempotencyTTL),
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return nil, false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return &record, true, nil
+		}
+
+		var existing models.IdempotencyRecord
+		err := db.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // released in the meantime
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		now := time.Now()
+		abandoned := existing.StatusCode == 0 && existing.CreatedAt.Before(now.Add(-idempotencyLockTimeout))
+		if !existing.ExpiresAt.After(now) || abandoned {
+			if err := db.Delete(&models.IdempotencyRecord{}, existing.ID).Error; err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		return &existing, false, nil
+	}
+	return nil, false, errIdempotencyKeyBusy
+}
+
+// requestHash identifies a request by its method, path and body
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// PurgeExpiredIdempotencyKeys deletes records whose keys are no longer
+// remembered
+func PurgeExpiredIdempotencyKeys(db *gorm.DB) (int64, error) {
+	result := db.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
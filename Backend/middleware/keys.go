@@ -0,0 +1,304 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one key in the keyring. Keys without private material can
+// only verify tokens, which is how a retired key is kept during rotation.
+type SigningKey struct {
+	ID        string
+	Algorithm string // HS256, RS256 or EdDSA
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// Keyring holds every key accepted for verification and names the one used
+// to sign new tokens. Rotating keys means adding a new key, making it
+// active, and dropping the old one once tokens signed with it have expired.
+type Keyring struct {
+	keys   map[string]*SigningKey
+	active *SigningKey
+}
+
+// keyFile is the JSON layout of JWT_KEYS_FILE:
+//
+//	{
+//	  "active": "2025-02",
+//	  "keys": [
+//	    {"kid": "2025-02", "alg": "EdDSA", "private_key_file": "keys/ed25519.pem"},
+//	    {"kid": "2024-11", "alg": "RS256", "public_key_file": "keys/rsa.pub.pem"},
+//	    {"kid": "legacy", "alg": "HS256", "secret": "..."}
+//	  ]
+//	}
+type keyFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		ID             string `json:"kid"`
+		Algorithm      string `json:"alg"`
+		Secret         string `json:"secret"`
+		PrivateKey     string `json:"private_key"`
+		PrivateKeyFile string `json:"private_key_file"`
+		PublicKeyFile  string `json:"public_key_file"`
+	} `json:"keys"`
+}
+
+var keyring *Keyring
+
+var errKeysNotLoaded = errors.New("signing keys not loaded")
+
+// LoadKeys builds the signing keyring from the environment:
+//
+//	JWT_KEYS_FILE         JSON keyring file (see keyFile); takes precedence
+//	JWT_ALG               HS256 (default), RS256 or EdDSA for a single key
+//	JWT_SECRET            HS256 secret
+//	JWT_PRIVATE_KEY_FILE  PEM private key for RS256 or EdDSA
+//	JWT_KID               key ID of the single key (default "default")
+//
+// Without any configuration a random HS256 secret is generated, so tokens
+// stop working when the process restarts.
+func LoadKeys() error {
+	ring, err := loadKeyring()
+	if err != nil {
+		return err
+	}
+	keyring = ring
+	return nil
+}
+
+func loadKeyring() (*Keyring, error) {
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		return loadKeyFile(path)
+	}
+
+	alg := EnvOr("JWT_ALG", jwt.SigningMethodHS256.Alg())
+	kid := EnvOr("JWT_KID", "default")
+
+	switch {
+	case os.Getenv("JWT_PRIVATE_KEY_FILE") != "":
+		pem, err := os.ReadFile(os.Getenv("JWT_PRIVATE_KEY_FILE"))
+		if err != nil {
+			return nil, err
+		}
+		key, err := newPrivateKey(kid, alg, pem)
+		if err != nil {
+			return nil, err
+		}
+		return newKeyring(key.ID, key)
+	case os.Getenv("JWT_SECRET") != "":
+		key, err := newSecretKey(kid, alg, []byte(os.Getenv("JWT_SECRET")))
+		if err != nil {
+			return nil, err
+		}
+		return newKeyring(key.ID, key)
+	}
+
+	log.Println("WARNING: no JWT signing key configured; using a random secret, tokens will not survive a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key, err := newSecretKey(kid, jwt.SigningMethodHS256.Alg(), secret)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyring(key.ID, key)
+}
+
+func loadKeyFile(path string) (*Keyring, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	var keys []*SigningKey
+	for _, entry := range file.Keys {
+		var key *SigningKey
+		switch {
+		case entry.Secret != "":
+			key, err = newSecretKey(entry.ID, entry.Algorithm, []byte(entry.Secret))
+		case entry.PrivateKey != "":
+			key, err = newPrivateKey(entry.ID, entry.Algorithm, []byte(entry.PrivateKey))
+		case entry.PrivateKeyFile != "":
+			var pem []byte
+			if pem, err = os.ReadFile(entry.PrivateKeyFile); err == nil {
+				key, err = newPrivateKey(entry.ID, entry.Algorithm, pem)
+			}
+		case entry.PublicKeyFile != "":
+			var pem []byte
+			if pem, err = os.ReadFile(entry.PublicKeyFile); err == nil {
+				key, err = newPublicKey(entry.ID, entry.Algorithm, pem)
+			}
+		default:
+			err = errors.New("no key material")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return newKeyring(file.Active, keys...)
+}
+
+func newKeyring(active string, keys ...*SigningKey) (*Keyring, error) {
+	ring := &Keyring{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("every signing key needs a kid")
+		}
+		if _, dup := ring.keys[key.ID]; dup {
+			return nil, fmt.Errorf("duplicate signing key %q", key.ID)
+		}
+		ring.keys[key.ID] = key
+	}
+
+	ring.active = ring.keys[active]
+	if ring.active == nil {
+		return nil, fmt.Errorf("active signing key %q not found", active)
+	}
+	if ring.active.signKey == nil {
+		return nil, fmt.Errorf("active signing key %q has no private key", active)
+	}
+	return ring, nil
+}
+
+func newSecretKey(kid, alg string, secret []byte) (*SigningKey, error) {
+	if alg != jwt.SigningMethodHS256.Alg() {
+		return nil, fmt.Errorf("a shared secret can only be used with HS256, not %q", alg)
+	}
+	if len(secret) < 32 {
+		return nil, errors.New("HS256 secrets must be at least 32 bytes")
+	}
+	return &SigningKey{ID: kid, Algorithm: alg, signKey: secret, verifyKey: secret}, nil
+}
+
+func newPrivateKey(kid, alg string, pem []byte) (*SigningKey, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: kid, Algorithm: alg, signKey: key, verifyKey: &key.PublicKey}, nil
+	case jwt.SigningMethodEdDSA.Alg():
+		key, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		private := key.(ed25519.PrivateKey)
+		return &SigningKey{ID: kid, Algorithm: alg, signKey: private, verifyKey: private.Public()}, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q for a private key", alg)
+}
+
+func newPublicKey(kid, alg string, pem []byte) (*SigningKey, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: kid, Algorithm: alg, verifyKey: key}, nil
+	case jwt.SigningMethodEdDSA.Alg():
+		key, err := jwt.ParseEdPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: kid, Algorithm: alg, verifyKey: key}, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q for a public key", alg)
+}
+
+// sign signs claims with the active key and names it in the kid header
+func (r *Keyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(r.active.Algorithm), claims)
+	token.Header["kid"] = r.active.ID
+	return token.SignedString(r.active.signKey)
+}
+
+// keyfunc resolves the verification key from the kid header and rejects a
+// token whose alg differs from the algorithm registered for that key, which
+// rules out algorithm confusion such as an RSA public key used as an HMAC
+// secret or "none"
+func (r *Keyring) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+// algorithms lists the algorithms of every key, for jwt.WithValidMethods
+func (r *Keyring) algorithms() []string {
+	seen := map[string]bool{}
+	var algs []string
+	for _, key := range r.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algs = append(algs, key.Algorithm)
+		}
+	}
+	return algs
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// PublicJWKS returns the public keys other services can use to verify our
+// tokens. Shared HS256 secrets are never published.
+func PublicJWKS() []JWK {
+	jwks := []JWK{}
+	if keyring == nil {
+		return jwks
+	}
+
+	for _, key := range keyring.keys {
+		jwk := JWK{KeyID: key.ID, Algorithm: key.Algorithm, Use: "sig"}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].KeyID < jwks[j].KeyID })
+	return jwks
+}
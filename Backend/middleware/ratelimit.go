@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"shopping-cart-backend/problem"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Login throttling, overridable with LOGIN_RATE_LIMIT (attempts) and
+// LOGIN_RATE_WINDOW (a duration such as "1m")
+var (
+	loginRateLimit  = EnvIntOr("LOGIN_RATE_LIMIT", 10)
+	loginRateWindow = EnvDurationOr("LOGIN_RATE_WINDOW", time.Minute)
+)
+
+// RateLimiter counts requests per key in fixed windows. It is held in memory,
+// so each server instance enforces its own limit.
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// NewRateLimiter allows limit requests per key in every window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// Allow records a request for key. When the limit is exceeded it reports
+// false and how long until the key's window resets.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop finished windows now and then so the map does not grow unbounded
+	if now.Sub(l.lastSweep) > l.window {
+		for k, w := range l.windows {
+			if !now.Before(w.resetAt) {
+				delete(l.windows, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &rateWindow{resetAt: now.Add(l.window)}
+		l.windows[key] = w
+	}
+	if w.count >= l.limit {
+		return false, w.resetAt.Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// RateLimitByIP rejects clients that exceed the limiter's rate with
+// 429 Too Many Requests
+func RateLimitByIP(l *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ok, retryAfter := l.Allow(c.ClientIP()); !ok {
+			SetRetryAfter(c, retryAfter)
+			Abort(c, problem.New(http.StatusTooManyRequests, problem.CodeRateLimited, "Too many requests, try again later"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// LoginRateLimit throttles login attempts per client IP
+func LoginRateLimit() gin.HandlerFunc {
+	return RateLimitByIP(NewRateLimiter(loginRateLimit, loginRateWindow))
+}
+
+// SetRetryAfter sets the Retry-After header in whole seconds, rounded up
+func SetRetryAfter(c *gin.Context, d time.Duration) {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+}
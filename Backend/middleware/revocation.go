@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"shopping-cart-backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokeToken adds an access token to the revocation list. Revoking the same
+// token twice is harmless.
+func RevokeToken(db *gorm.DB, claims *Claims) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}).Error
+}
+
+// IsTokenRevoked reports whether the access token with this jti was revoked
+func IsTokenRevoked(db *gorm.DB, jti string) (bool, error) {
+	var count int64
+	err := db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// PurgeExpiredRevocations deletes revocation entries for tokens that have
+// expired, since expiry alone now rejects them
+func PurgeExpiredRevocations(db *gorm.DB) (int64, error) {
+	result := db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+	return result.RowsAffected, result.Error
+}
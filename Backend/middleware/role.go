@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"shopping-cart-backend/models"
+	"shopping-cart-backend/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets through users holding one of the given roles.
+// It must be registered after AuthMiddleware, which loads the user.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		user, ok := value.(models.User)
+		if !exists || !ok {
+			Abort(c, problem.New(http.StatusUnauthorized, problem.CodeAuthRequired, "Authentication required"))
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		Abort(c, problem.New(http.StatusForbidden, problem.CodeForbidden, "Insufficient permissions"))
+	}
+}
@@ -2,41 +2,84 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// User roles
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
 )
 
 // User model
 type User struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Username  string    `json:"username" gorm:"unique;not null"`
-	Password  string    `json:"-" gorm:"not null"` // "-" prevents password from being serialized
-	Token     string    `json:"token"`
+	Password  string    `json:"-" gorm:"not null"`                       // "-" prevents password from being serialized
+	Role      string    `json:"role" gorm:"not null;default:'customer'"` // customer, admin
 	CartID    *uint     `json:"cart_id"`
 	CreatedAt time.Time `json:"created_at"`
-	
+
+	// Profile. Email is stored lower-cased and is unique when set.
+	Email       *string `json:"email" gorm:"uniqueIndex;size:254"`
+	DisplayName string  `json:"display_name" gorm:"size:100"`
+
+	// Failed logins since the last success; reaching the limit locks the
+	// account until LockedUntil
+	FailedLoginAttempts int        `json:"-" gorm:"not null;default:0"`
+	LockedUntil         *time.Time `json:"-"`
+
 	// Relationships
 	Cart   *Cart   `json:"cart,omitempty" gorm:"foreignKey:CartID"`
 	Orders []Order `json:"orders,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// Item statuses
+const (
+	ItemStatusAvailable   = "available"
+	ItemStatusUnavailable = "unavailable"
+)
+
+// ValidItemStatus reports whether status is one of the known item statuses
+func ValidItemStatus(status string) bool {
+	return status == ItemStatusAvailable || status == ItemStatusUnavailable
+}
+
 // Item model
 type Item struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Name      string    `json:"name" gorm:"not null"`
-	Status    string    `json:"status" gorm:"default:'available'"` // available, unavailable
-	CreatedAt time.Time `json:"created_at"`
-	
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	Price       int64     `json:"price" gorm:"not null;default:0"` // minor units (e.g. paise, cents)
+	Currency    string    `json:"currency" gorm:"size:3;not null;default:'INR'"`
+	Stock       int       `json:"stock" gorm:"not null;default:0"`   // units on hand
+	Status      string    `json:"status" gorm:"default:'available'"` // available, unavailable
+	SoldOut     bool      `json:"-" gorm:"not null;default:false"`   // made unavailable only because stock ran out
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Soft delete keeps the row so historical orders still resolve their items
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
 	// Relationships
 	CartItems []CartItem `json:"cart_items,omitempty" gorm:"foreignKey:ItemID"`
 }
 
+// Cart statuses
+const (
+	CartStatusActive  = "active"  // the user's open cart
+	CartStatusOrdered = "ordered" // checked out into an order
+)
+
 // Cart model
 type Cart struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	UserID    uint      `json:"user_id" gorm:"not null"`
 	Name      string    `json:"name"`
-	Status    string    `json:"status" gorm:"default:'active'"` // active, ordered
+	Status    string    `json:"status" gorm:"default:'active'"` // CartStatus*
 	CreatedAt time.Time `json:"created_at"`
-	
+
 	// Relationships
 	User      User       `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Items     []Item     `json:"items,omitempty" gorm:"many2many:cart_items;"`
@@ -48,20 +91,168 @@ type CartItem struct {
 	CartID   uint `json:"cart_id" gorm:"primaryKey"`
 	ItemID   uint `json:"item_id" gorm:"primaryKey"`
 	Quantity int  `json:"quantity" gorm:"default:1"`
-	
+
+	// Snapshot taken when the item was added, so later catalog changes
+	// affect neither what the customer is charged nor what the order shows
+	ItemName  string `json:"item_name" gorm:"size:100;not null;default:''"`
+	UnitPrice int64  `json:"unit_price" gorm:"not null;default:0"`
+	Currency  string `json:"currency" gorm:"size:3;not null;default:'INR'"`
+
 	// Relationships
 	Cart Cart `json:"cart,omitempty" gorm:"foreignKey:CartID"`
 	Item Item `json:"item,omitempty" gorm:"foreignKey:ItemID"`
 }
 
+// LineTotal returns the snapshot price multiplied by the quantity
+func (ci CartItem) LineTotal() int64 {
+	return ci.UnitPrice * int64(ci.Quantity)
+}
+
+// Order statuses
+const (
+	OrderStatusPending   = "pending"   // placed, awaiting payment
+	OrderStatusPaid      = "paid"      // payment captured
+	OrderStatusFulfilled = "fulfilled" // picked and packed
+	OrderStatusShipped   = "shipped"   // handed to the carrier
+	OrderStatusDelivered = "delivered" // received by the customer
+	OrderStatusCancelled = "cancelled" // abandoned before shipping
+	OrderStatusRefunded  = "refunded"  // money returned after payment
+)
+
+// OrderStatuses lists every order status in lifecycle order
+var OrderStatuses = []string{
+	OrderStatusPending, OrderStatusPaid, OrderStatusFulfilled, OrderStatusShipped,
+	OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded,
+}
+
+// orderTransitions is the order state machine: the statuses each status may
+// move to. Cancelled and refunded are final.
+var orderTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusFulfilled, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusFulfilled: {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusShipped:   {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered: {OrderStatusRefunded},
+	OrderStatusCancelled: {},
+	OrderStatusRefunded:  {},
+}
+
+// ValidOrderStatus reports whether status is one of the known order statuses
+func ValidOrderStatus(status string) bool {
+	_, ok := orderTransitions[status]
+	return ok
+}
+
+// NextOrderStatuses lists the statuses an order in status may move to
+func NextOrderStatuses(status string) []string {
+	return orderTransitions[status]
+}
+
+// CanTransitionOrder reports whether an order may move from one status to another
+func CanTransitionOrder(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomerCanCancelOrder reports whether customers may cancel their own
+// order in status; later on only admins can. Cancelling returns the
+// order's items to stock.
+func CustomerCanCancelOrder(status string) bool {
+	return status == OrderStatusPending || status == OrderStatusPaid
+}
+
 // Order model
 type Order struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	CartID    uint      `json:"cart_id" gorm:"not null"`
-	UserID    uint      `json:"user_id" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
-	
+	UserID    uint      `json:"user_id" gorm:"not null;index:idx_orders_user_created,priority:1"`
+	Status    string    `json:"status" gorm:"not null;default:'pending';index"`
+	Subtotal  int64     `json:"subtotal" gorm:"not null;default:0"` // sum of line totals, minor units
+	Total     int64     `json:"total" gorm:"not null;default:0"`    // amount charged, minor units
+	Currency  string    `json:"currency" gorm:"size:3;not null;default:'INR'"`
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_orders_user_created,priority:2"`
+
 	// Relationships
-	Cart Cart `json:"cart,omitempty" gorm:"foreignKey:CartID"`
-	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Cart          Cart                 `json:"cart,omitempty" gorm:"foreignKey:CartID"`
+	User          User                 `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	StatusHistory []OrderStatusHistory `json:"status_history,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+// OrderStatusHistory records one status change of an order: who made it,
+// when, and why. FromStatus is empty for the entry created with the order.
+type OrderStatusHistory struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	OrderID     uint      `json:"order_id" gorm:"not null;index"`
+	FromStatus  string    `json:"from_status"`
+	ToStatus    string    `json:"to_status" gorm:"not null"`
+	ChangedByID *uint     `json:"changed_by_id"` // nil for system changes
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Session is one logged-in device. Access tokens name their session in the
+// sid claim and are only accepted while it is not revoked.
+type Session struct {
+	ID          string     `json:"id" gorm:"primaryKey;size:32"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	DeviceLabel string     `json:"device_label"`
+	IP          string     `json:"ip"`
+	UserAgent   string     `json:"user_agent"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// RefreshToken is one link in a rotating refresh-token chain. Only a hash of
+// the token is stored. Every token issued for the same session shares that
+// session's ID as FamilyID, so a replayed token can revoke the whole chain.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	FamilyID  string     `json:"family_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`    // set when exchanged for a new token
+	RevokedAt *time.Time `json:"revoked_at"` // set when the family is revoked
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RevokedToken blocks an access token by its jti until the token would have
+// expired anyway, after which the row can be purged
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey;size:64"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PasswordResetToken lets a user set a new password without the old one.
+// Only a hash of the token is stored, and it can be used once before it
+// expires.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// IdempotencyRecord remembers a request made with an Idempotency-Key header
+// so a retry gets the original response instead of repeating the work.
+// StatusCode is 0 while the first request is still being handled.
+type IdempotencyRecord struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `json:"key" gorm:"column:idempotency_key;size:255;not null;uniqueIndex:idx_idempotency_user_key"`
+	RequestHash  string    `json:"-" gorm:"size:64;not null"` // SHA-256 of method, path and body
+	StatusCode   int       `json:"status_code" gorm:"not null;default:0"`
+	ContentType  string    `json:"-"`
+	ResponseBody []byte    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
 }
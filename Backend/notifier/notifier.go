@@ -0,0 +1,85 @@
+// Package notifier delivers messages such as password reset links to users.
+// Deployments plug in a real transport (email, SMS) by implementing Notifier;
+// the log and file notifiers are meant for local development.
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is one notification for a user
+type Message struct {
+	UserID  uint
+	To      string // recipient address, or the username when none is known
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the notifier used by Send. It logs messages until Configure
+// selects another one.
+var Default Notifier = LogNotifier{}
+
+// Configure selects Default from the environment:
+//
+//	NOTIFIER       "log" (default) or "file"
+//	NOTIFIER_FILE  file the "file" notifier appends to (default notifications.log)
+func Configure() error {
+	switch kind := strings.ToLower(os.Getenv("NOTIFIER")); kind {
+	case "", "log":
+		Default = LogNotifier{}
+	case "file":
+		path := os.Getenv("NOTIFIER_FILE")
+		if path == "" {
+			path = "notifications.log"
+		}
+		Default = &FileNotifier{Path: path}
+	default:
+		return fmt.Errorf("unknown NOTIFIER %q", kind)
+	}
+	return nil
+}
+
+// Send delivers msg through Default
+func Send(ctx context.Context, msg Message) error {
+	return Default.Send(ctx, msg)
+}
+
+// LogNotifier writes messages to the standard logger
+type LogNotifier struct{}
+
+func (LogNotifier) Send(_ context.Context, msg Message) error {
+	log.Printf("Notification to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileNotifier appends messages to a file, e.g. for tests or a local mailbox
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (n *FileNotifier) Send(_ context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	return err
+}
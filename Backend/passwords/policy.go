@@ -0,0 +1,128 @@
+// Package passwords holds the rules new passwords must satisfy.
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MaxLength is the longest password accepted; bcrypt ignores anything past
+// 72 bytes, so longer passwords would silently be truncated
+const MaxLength = 72
+
+// Policy is the set of rules a new password must satisfy
+type Policy struct {
+	MinLength int
+	// breached holds upper-case SHA-1 digests of known breached passwords
+	breached map[string]bool
+}
+
+// commonPasswords is a small built-in breached list, used on top of any
+// list loaded from PASSWORD_BREACHED_LIST
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "12345", "1234567",
+	"password", "password1", "password123", "passw0rd", "qwerty", "qwerty123",
+	"qwertyuiop", "abc123", "111111", "000000", "123123", "654321", "iloveyou",
+	"admin", "admin123", "welcome", "welcome1", "letmein", "monkey", "dragon",
+	"football", "baseball", "sunshine", "princess", "superman", "trustno1",
+	"1q2w3e4r", "zaq12wsx", "changeme", "secret", "shopping", "login",
+}
+
+var policy = defaultPolicy()
+
+func defaultPolicy() *Policy {
+	p := &Policy{MinLength: 8, breached: make(map[string]bool, len(commonPasswords))}
+	for _, password := range commonPasswords {
+		p.breached[digest(password)] = true
+	}
+	return p
+}
+
+// LoadPolicy configures the policy from the environment:
+//
+//	PASSWORD_MIN_LENGTH     minimum length in characters (default 8)
+//	PASSWORD_BREACHED_LIST  file of breached passwords, one per line, either
+//	                        as plain text or as SHA-1 hex digests optionally
+//	                        followed by ":count" (the Have I Been Pwned format)
+func LoadPolicy() error {
+	p := defaultPolicy()
+
+	if raw := os.Getenv("PASSWORD_MIN_LENGTH"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > MaxLength {
+			return fmt.Errorf("PASSWORD_MIN_LENGTH must be between 1 and %d", MaxLength)
+		}
+		p.MinLength = n
+	}
+
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		if err := p.loadBreachedList(path); err != nil {
+			return fmt.Errorf("load breached password list: %w", err)
+		}
+	}
+
+	policy = p
+	return nil
+}
+
+func (p *Policy) loadBreachedList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1(hash) {
+			p.breached[strings.ToUpper(hash)] = true
+			continue
+		}
+		p.breached[digest(line)] = true
+	}
+	return scanner.Err()
+}
+
+// Check returns an error describing the first rule the password breaks, or
+// nil when it is acceptable for the given username
+func Check(password, username string) error {
+	return policy.Check(password, username)
+}
+
+// Check returns an error describing the first rule the password breaks
+func (p *Policy) Check(password, username string) error {
+	switch {
+	case len([]rune(password)) < p.MinLength:
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	case len(password) > MaxLength:
+		return fmt.Errorf("password must be at most %d bytes", MaxLength)
+	case username != "" && strings.EqualFold(password, username):
+		return errors.New("password must not match the username")
+	case p.breached[digest(password)] || p.breached[digest(strings.ToLower(password))]:
+		return errors.New("password appears in a list of breached passwords; choose another")
+	}
+	return nil
+}
+
+func digest(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1(s string) bool {
+	if len(s) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,50 @@
+package problem
+
+// Error codes. They are part of the API contract: add new ones freely, but
+// never rename or reuse an existing code.
+const (
+	// Generic
+	CodeInvalidRequest   = "INVALID_REQUEST"   // malformed body
+	CodeValidationFailed = "VALIDATION_FAILED" // see the errors member
+	CodeInvalidParameter = "INVALID_PARAMETER" // bad path or query parameter
+	CodeNotFound         = "NOT_FOUND"         // no such route
+	CodeInternal         = "INTERNAL_ERROR"
+
+	// Authentication and authorization
+	CodeAuthRequired        = "AUTH_REQUIRED"
+	CodeTokenInvalid        = "TOKEN_INVALID"
+	CodeTokenRevoked        = "TOKEN_REVOKED"
+	CodeSessionRevoked      = "SESSION_REVOKED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeInvalidCredentials  = "INVALID_CREDENTIALS"
+	CodeAccountLocked       = "ACCOUNT_LOCKED"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeRefreshTokenInvalid = "REFRESH_TOKEN_INVALID"
+	CodeRefreshTokenReused  = "REFRESH_TOKEN_REUSED"
+	CodeResetTokenInvalid   = "RESET_TOKEN_INVALID"
+	CodeSessionNotFound     = "SESSION_NOT_FOUND"
+
+	// Accounts
+	CodeUsernameTaken     = "USERNAME_TAKEN"
+	CodeEmailTaken        = "EMAIL_TAKEN"
+	CodeWeakPassword      = "WEAK_PASSWORD"
+	CodePasswordIncorrect = "PASSWORD_INCORRECT"
+
+	// Catalog
+	CodeItemNotFound    = "ITEM_NOT_FOUND"
+	CodeItemUnavailable = "ITEM_UNAVAILABLE"
+	CodeItemRemoved     = "ITEM_REMOVED" // deleted from the catalog
+
+	// Carts and orders
+	CodeCartNotFound         = "CART_NOT_FOUND"
+	CodeCartItemNotFound     = "CART_ITEM_NOT_FOUND"
+	CodeCartEmpty            = "CART_EMPTY"
+	CodeCurrencyMismatch     = "CURRENCY_MISMATCH"
+	CodeQuantityLimit        = "QUANTITY_LIMIT_EXCEEDED"
+	CodeOutOfStock           = "OUT_OF_STOCK"
+	CodeOrderNotFound        = "ORDER_NOT_FOUND"
+	CodeInvalidTransition    = "INVALID_ORDER_TRANSITION"
+	CodeOrderNotCancellable  = "ORDER_NOT_CANCELLABLE"
+	CodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInUse  = "IDEMPOTENCY_KEY_IN_USE"
+)
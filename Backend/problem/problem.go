@@ -0,0 +1,176 @@
+// Package problem defines the error type handlers report and its RFC 7807
+// (application/problem+json) rendering. Every error carries a stable,
+// machine-readable code from codes.go that clients can match on instead of
+// the English detail text.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"shopping-cart-backend/validation"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ContentType is the media type of a rendered problem
+const ContentType = "application/problem+json"
+
+// Error is an API error. Status and Code decide how clients handle it;
+// Detail is for humans. Err, the underlying cause, is logged but never
+// sent to the client.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []FieldError
+	Extra  map[string]interface{}
+	Err    error
+}
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// New returns an error with the given HTTP status, code and detail
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// Invalid returns a 400 validation problem for a single request field
+func Invalid(field, code, message string) *Error {
+	e := New(http.StatusBadRequest, CodeValidationFailed, message)
+	e.Fields = []FieldError{{Field: field, Code: code, Message: message}}
+	return e
+}
+
+// InvalidParameter returns a 400 problem for a malformed path or query
+// parameter
+func InvalidParameter(name, message string) *Error {
+	e := New(http.StatusBadRequest, CodeInvalidParameter, message)
+	e.Fields = []FieldError{{Field: name, Code: "invalid", Message: message}}
+	return e
+}
+
+// Internal returns a 500 error that keeps err as its logged cause
+func Internal(detail string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: detail, Err: err}
+}
+
+// With adds an extension member, such as the stock still available, to the
+// rendered problem
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Extra == nil {
+		e.Extra = map[string]interface{}{}
+	}
+	e.Extra[key] = value
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Detail + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Body is the problem+json document for e. Extension members are merged
+// alongside the standard ones.
+func (e *Error) Body(instance, requestID string) map[string]interface{} {
+	body := make(map[string]interface{}, len(e.Extra)+7)
+	for key, value := range e.Extra {
+		body[key] = value
+	}
+	body["type"] = "about:blank"
+	body["title"] = http.StatusText(e.Status)
+	body["status"] = e.Status
+	body["detail"] = e.Detail
+	body["code"] = e.Code
+	body["instance"] = instance
+	if requestID != "" {
+		body["request_id"] = requestID
+	}
+	if len(e.Fields) > 0 {
+		body["errors"] = e.Fields
+	}
+	return body
+}
+
+// As returns err as an *Error, wrapping anything else as an internal error
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal("Internal server error", err)
+}
+
+// Binding converts an error from gin's ShouldBind* into a 400 problem,
+// listing each invalid field
+func Binding(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		e := New(http.StatusBadRequest, CodeValidationFailed, "Request validation failed")
+		for _, fe := range validationErrs {
+			e.Fields = append(e.Fields, FieldError{
+				Field:   validation.FieldPath(fe),
+				Code:    fe.Tag(),
+				Message: validation.Message(fe),
+			})
+		}
+		if len(e.Fields) == 1 {
+			e.Detail = e.Fields[0].Message
+		}
+		return e
+	case errors.As(err, &typeErr):
+		e := New(http.StatusBadRequest, CodeValidationFailed, "Request validation failed")
+		e.Fields = []FieldError{{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: typeErr.Field + " must be " + jsonType(typeErr.Type),
+		}}
+		e.Detail = e.Fields[0].Message
+		return e
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Detail: "Request body is not valid JSON", Err: err}
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Detail: "Invalid request", Err: err}
+}
+
+// jsonType describes the JSON value a Go field accepts, so type errors do
+// not expose Go type names
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a positive integer"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a valid value"
+}
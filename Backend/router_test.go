@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"shopping-cart-backend/database"
+	"shopping-cart-backend/middleware"
+	"shopping-cart-backend/passwords"
+	"shopping-cart-backend/problem"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// secretMarkers must never appear in a response body
+var secretMarkers = []string{
+	"password",
+	"$2a$",
+	"token_hash",
+	"failed_login_attempts",
+	"locked_until",
+}
+
+// testServer serves the full router over a migrated in-memory database
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := database.Open(database.Config{Driver: "sqlite", DSN: database.MemoryDSN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := passwords.LoadPolicy(); err != nil {
+		t.Fatal(err)
+	}
+	if err := middleware.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	return &testServer{t: t, router: newRouter()}
+}
+
+// do sends a JSON request and fails the test unless it gets wantStatus
+func (s *testServer) do(method, path, token string, body interface{}, wantStatus int) []byte {
+	s.t.Helper()
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != wantStatus {
+		s.t.Fatalf("%s %s: status %d, want %d: %s", method, path, w.Code, wantStatus, w.Body.String())
+	}
+	return w.Body.Bytes()
+}
+
+// login returns the access token and the login body without its tokens,
+// which are the one place credentials are meant to be returned
+func (s *testServer) login(username, password string) (string, []byte) {
+	s.t.Helper()
+	body := s.do(http.MethodPost, "/users/login", "", gin.H{"username": username, "password": password}, http.StatusOK)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		s.t.Fatal(err)
+	}
+	token, _ := response["token"].(string)
+	if token == "" {
+		s.t.Fatalf("login returned no token: %s", body)
+	}
+	delete(response, "token")
+	delete(response, "refresh_token")
+	rest, err := json.Marshal(response)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token, rest
+}
+
+// loginAdmin creates an admin account and logs it in like login
+func (s *testServer) loginAdmin() (string, []byte) {
+	s.t.Helper()
+	const password = "Adm1n-Sup3r-Secret!"
+	if err := database.EnsureAdmin("boss", password); err != nil {
+		s.t.Fatal(err)
+	}
+	return s.login("boss", password)
+}
+
+func TestResponsesNeverExposeSecrets(t *testing.T) {
+	s := newTestServer(t)
+
+	adminToken, adminLogin := s.loginAdmin()
+	s.do(http.MethodPost, "/items", adminToken, gin.H{"name": "Pen", "price": 500, "stock": 10}, http.StatusCreated)
+
+	bodies := map[string][]byte{
+		"POST /users": s.do(http.MethodPost, "/users", "", gin.H{
+			"username": "alice", "password": "Correct-Horse-42", "email": "alice@example.com",
+		}, http.StatusCreated),
+		"POST /users/login (admin)": adminLogin,
+	}
+
+	// A failed login must not leak lockout state either
+	bodies["POST /users/login (wrong password)"] = s.do(http.MethodPost, "/users/login", "",
+		gin.H{"username": "alice", "password": "wrong-password"}, http.StatusUnauthorized)
+
+	token, login := s.login("alice", "Correct-Horse-42")
+	bodies["POST /users/login"] = login
+	bodies["POST /carts"] = s.do(http.MethodPost, "/carts", token, gin.H{"item_id": 1}, http.StatusCreated)
+	bodies["GET /carts"] = s.do(http.MethodGet, "/carts", token, nil, http.StatusOK)
+	bodies["POST /orders"] = s.do(http.MethodPost, "/orders", token, nil, http.StatusCreated)
+	bodies["GET /orders"] = s.do(http.MethodGet, "/orders", token, nil, http.StatusOK)
+	bodies["GET /orders/:id"] = s.do(http.MethodGet, "/orders/1", token, nil, http.StatusOK)
+	bodies["GET /users/me"] = s.do(http.MethodGet, "/users/me", token, nil, http.StatusOK)
+	bodies["GET /sessions"] = s.do(http.MethodGet, "/sessions", token, nil, http.StatusOK)
+	bodies["GET /users"] = s.do(http.MethodGet, "/users", adminToken, nil, http.StatusOK)
+	bodies["GET /items"] = s.do(http.MethodGet, "/items", "", nil, http.StatusOK)
+	bodies["GET /items/:id"] = s.do(http.MethodGet, "/items/1", "", nil, http.StatusOK)
+	bodies["GET /items/search"] = s.do(http.MethodGet, "/items/search?q=pen", "", nil, http.StatusOK)
+	bodies["PUT /orders/:id/status"] = s.do(http.MethodPut, "/orders/1/status", adminToken,
+		gin.H{"status": "paid", "reason": "payment received"}, http.StatusOK)
+	bodies["GET /orders/:id (after status change)"] = s.do(http.MethodGet, "/orders/1", token, nil, http.StatusOK)
+	bodies["GET /orders/:id (admin)"] = s.do(http.MethodGet, "/orders/1", adminToken, nil, http.StatusOK)
+
+	// Error responses are problem+json documents and must not leak either
+	bodies["GET /orders/:id (not found)"] = s.do(http.MethodGet, "/orders/999", token, nil, http.StatusNotFound)
+	bodies["POST /carts (validation error)"] = s.do(http.MethodPost, "/carts", token, gin.H{"quantity": -1}, http.StatusBadRequest)
+	bodies["GET /users/me (bad token)"] = s.do(http.MethodGet, "/users/me", "not-a-token", nil, http.StatusUnauthorized)
+
+	for route, body := range bodies {
+		for _, marker := range secretMarkers {
+			if strings.Contains(string(body), marker) {
+				t.Errorf("%s response contains %q: %s", route, marker, body)
+			}
+		}
+	}
+}
+
+func TestLoginRateLimitIgnoresForwardedFor(t *testing.T) {
+	s := newTestServer(t)
+
+	// One more attempt than the default LOGIN_RATE_LIMIT, each claiming a new address
+	var last *httptest.ResponseRecorder
+	for i := 0; i <= 10; i++ {
+		body := fmt.Sprintf(`{"username":"nobody-%d","password":"wrong-password"}`, i)
+		req := httptest.NewRequest(http.MethodPost, "/users/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))
+		last = httptest.NewRecorder()
+		s.router.ServeHTTP(last, req)
+	}
+
+	if last.Code != http.StatusTooManyRequests || !strings.Contains(last.Body.String(), problem.CodeRateLimited) {
+		t.Errorf("last login: status %d %s, want 429 %s", last.Code, last.Body, problem.CodeRateLimited)
+	}
+}
+
+func TestCartQuantityIncreaseNeedsAvailableItem(t *testing.T) {
+	s := newTestServer(t)
+
+	adminToken, _ := s.loginAdmin()
+	s.do(http.MethodPost, "/items", adminToken, gin.H{"name": "Pen", "price": 500, "stock": 10}, http.StatusCreated)
+
+	s.do(http.MethodPost, "/users", "", gin.H{"username": "alice", "password": "Correct-Horse-42"}, http.StatusCreated)
+	token, _ := s.login("alice", "Correct-Horse-42")
+	s.do(http.MethodPost, "/carts", token, gin.H{"item_id": 1, "quantity": 3}, http.StatusCreated)
+
+	s.do(http.MethodPatch, "/items/1", adminToken, gin.H{"status": "unavailable"}, http.StatusOK)
+
+	body := s.do(http.MethodPut, "/carts/1", token, gin.H{"quantity": 4}, http.StatusBadRequest)
+	if !strings.Contains(string(body), problem.CodeItemUnavailable) {
+		t.Errorf("raising the quantity: %s, want %s", body, problem.CodeItemUnavailable)
+	}
+	s.do(http.MethodPut, "/carts/1", token, gin.H{"quantity": 2}, http.StatusOK)
+}
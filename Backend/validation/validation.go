@@ -0,0 +1,132 @@
+// Package validation extends gin's request validator with the app's own
+// rules and turns validation failures into field paths and human messages.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"shopping-cart-backend/models"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Username rules: 3 to 32 letters, digits, '.', '_' or '-', starting with a
+// letter or digit
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{2,31}$`)
+
+// currencyPattern accepts any case; handlers upper-case the code
+var currencyPattern = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+// init registers the custom rules on gin's validator, so any package whose
+// request structs use them only has to import this one:
+//
+//	username    see usernamePattern
+//	itemstatus  one of the models.ItemStatus* constants
+//	currency    a 3-letter ISO 4217 code
+//	orderstatus one of the models.OrderStatus* constants
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// Report fields by their JSON names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("itemstatus", func(fl validator.FieldLevel) bool {
+		return models.ValidItemStatus(fl.Field().String())
+	})
+	v.RegisterValidation("orderstatus", func(fl validator.FieldLevel) bool {
+		return models.ValidOrderStatus(fl.Field().String())
+	})
+	v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return currencyPattern.MatchString(strings.TrimSpace(fl.Field().String()))
+	})
+}
+
+// FieldPath is the JSON path of the invalid field, e.g. "quantity" or
+// "lines[2].quantity", without the request struct's name
+func FieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+// Message is a human-readable description of the failure, naming the field
+func Message(fe validator.FieldError) string {
+	field := FieldPath(fe)
+	param := fe.Param()
+	isString := fe.Kind() == reflect.String
+
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "required_without":
+		return fmt.Sprintf("%s is required when %s is not given", field, jsonName(param))
+	case "min", "gte":
+		if isString {
+			return fmt.Sprintf("%s must be at least %s characters long", field, param)
+		}
+		return fmt.Sprintf("%s must be at least %s", field, param)
+	case "max", "lte":
+		if isString {
+			return fmt.Sprintf("%s must be at most %s characters long", field, param)
+		}
+		return fmt.Sprintf("%s must be at most %s", field, param)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, param)
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s characters long", field, param)
+	case "nefield":
+		return fmt.Sprintf("%s must differ from %s", field, jsonName(param))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(param, " ", ", "))
+	case "email":
+		return field + " must be a valid email address"
+	case "username":
+		return field + " must be 3-32 letters, digits, '.', '_' or '-', starting with a letter or digit"
+	case "itemstatus":
+		return fmt.Sprintf("%s must be one of: %s, %s", field, models.ItemStatusAvailable, models.ItemStatusUnavailable)
+	case "orderstatus":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(models.OrderStatuses, ", "))
+	case "currency":
+		return field + " must be a 3-letter ISO 4217 currency code"
+	}
+	return fmt.Sprintf("%s failed the %q rule", field, fe.Tag())
+}
+
+// jsonName converts a Go field name used as a rule parameter, such as the
+// DisplayName in required_without=DisplayName, to its snake_case JSON name
+func jsonName(goName string) string {
+	var b strings.Builder
+	for i, r := range goName {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -1,4 +1,4 @@
-import { useState, useEffect } from 'react';
+import { useState, useEffect, useRef } from 'react';
 import toast from 'react-hot-toast';
 import { cartAPI, ordersAPI } from '../utils/api';
 
@@ -6,6 +6,9 @@ const CheckoutModal = ({ isOpen, onClose, onSuccess }) => {
   const [cartData, setCartData] = useState(null);
   const [isLoading, setIsLoading] = useState(false);
   const [isProcessing, setIsProcessing] = useState(false);
+  // One key per checkout attempt, reused by double-clicks and retries until
+  // an order is placed
+  const checkoutKey = useRef(null);
 
   useEffect(() => {
     if (isOpen) {
@@ -91,9 +94,14 @@ const CheckoutModal = ({ isOpen, onClose, onSuccess }) => {
       return;
     }
 
+    if (!checkoutKey.current) {
+      checkoutKey.current = crypto.randomUUID();
+    }
+
     setIsProcessing(true);
     try {
-      await ordersAPI.create();
+      await ordersAPI.create(checkoutKey.current);
+      checkoutKey.current = null;
       
       // Clear localStorage cart after successful checkout
       localStorage.removeItem('cart');
@@ -102,6 +110,8 @@ const CheckoutModal = ({ isOpen, onClose, onSuccess }) => {
       onSuccess?.();
       onClose();
     } catch (error) {
+      // A duplicate click while the first request is still running
+      if (error.code === 'IDEMPOTENCY_KEY_IN_USE') return;
       console.error('Error during checkout:', error);
       toast.error('Error processing order. Please try again.');
     } finally {
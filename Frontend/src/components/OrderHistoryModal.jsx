@@ -103,7 +103,7 @@ const OrderHistoryModal = ({ isOpen, onClose }) => {
                         {order.cart?.cart_items && order.cart.cart_items.length > 0 ? (
                           <p className="font-bold text-lg mt-1">
                             ₹{order.cart.cart_items.reduce((total, cartItem) => {
-                              return total + (getItemPrice(cartItem.item.name) * cartItem.quantity);
+                              return total + (getItemPrice((cartItem.item_name || cartItem.item?.name)) * cartItem.quantity);
                             }, 0).toLocaleString('en-IN')}
                           </p>
                         ) : order.total ? (
@@ -121,10 +121,10 @@ const OrderHistoryModal = ({ isOpen, onClose }) => {
                           {order.cart.cart_items.map((cartItem, index) => (
                             <div key={index} className="bg-base-200 p-2 rounded flex justify-between items-center">
                               <div>
-                                <span className="text-sm font-medium">{cartItem.item.name}</span>
+                                <span className="text-sm font-medium">{(cartItem.item_name || cartItem.item?.name)}</span>
                                 <span className="text-xs text-base-content/70 ml-2">x{cartItem.quantity}</span>
                               </div>
-                              <span className="text-sm font-medium">₹{(getItemPrice(cartItem.item.name) * cartItem.quantity).toLocaleString('en-IN')}</span>
+                              <span className="text-sm font-medium">₹{(getItemPrice((cartItem.item_name || cartItem.item?.name)) * cartItem.quantity).toLocaleString('en-IN')}</span>
                             </div>
                           ))}
                         </div>
@@ -1,7 +1,7 @@
-import { useState, useEffect } from 'react';
+import { useState, useEffect, useRef } from 'react';
 import { useNavigate } from 'react-router-dom';
 import toast from 'react-hot-toast';
-import { itemsAPI, cartAPI, ordersAPI, auth } from '../utils/api';
+import { itemsAPI, cartAPI, ordersAPI, authAPI, auth } from '../utils/api';
 import CartModal from '../components/CartModal';
 import CheckoutModal from '../components/CheckoutModal';
 import OrderHistoryModal from '../components/OrderHistoryModal';
@@ -10,6 +10,9 @@ const Items = ({ setIsAuthenticated }) => {
   const [items, setItems] = useState([]);
   const [isLoading, setIsLoading] = useState(true);
   const [addingItemId, setAddingItemId] = useState(null);
+  // Idempotency key per item being added, reused by double-clicks and
+  // retries until the add succeeds
+  const addKeys = useRef({});
   const [isCartModalOpen, setIsCartModalOpen] = useState(false);
   const [isCheckoutModalOpen, setIsCheckoutModalOpen] = useState(false);
   const [isOrderHistoryModalOpen, setIsOrderHistoryModalOpen] = useState(false);
@@ -36,7 +39,9 @@ const Items = ({ setIsAuthenticated }) => {
     try {
       console.log('🔍 Items: Adding item to cart:', itemId);
       // Add to backend first
-      await cartAPI.addItem(itemId);
+      addKeys.current[itemId] ??= crypto.randomUUID();
+      await cartAPI.addItem(itemId, addKeys.current[itemId]);
+      delete addKeys.current[itemId];
       
       console.log('🔍 Items: Item successfully added to backend cart');
       toast.success('Item added to cart successfully! ✅');
@@ -45,6 +50,8 @@ const Items = ({ setIsAuthenticated }) => {
       // This ensures consistency
       
     } catch (error) {
+      // A duplicate click while the first request is still running
+      if (error.code === 'IDEMPOTENCY_KEY_IN_USE') return;
       console.error('🔍 Items: Error adding item to cart:', error);
       toast.error('Failed to add item to cart. Please try again.');
     } finally {
@@ -84,7 +91,14 @@ const Items = ({ setIsAuthenticated }) => {
     fetchItems();
   };
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    // Revoke the session on the backend; log out locally even if that fails
+    try {
+      await authAPI.logout();
+    } catch (error) {
+      console.error('Logout request failed:', error);
+    }
+
     // Clear authentication and user data (this will clear cart automatically)
     auth.removeToken();
     setIsAuthenticated(false);
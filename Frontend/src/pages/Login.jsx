@@ -30,7 +30,7 @@ const Login = ({ setIsAuthenticated }) => {
       
       // Store token (this will automatically clear previous user's cart data)
       console.log('🔍 Login: Setting token and clearing previous cart data');
-      auth.setToken(response.token);
+      auth.setToken(response.token, response.refresh_token);
       
       // Update authentication state
       setIsAuthenticated(true);
@@ -3,24 +3,50 @@ const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:8081
 
 // Helper function to get token from localStorage
 const getToken = () => localStorage.getItem('token');
+const getRefreshToken = () => localStorage.getItem('refreshToken');
 
-// Helper function to make API requests
-const apiRequest = async (endpoint, options = {}) => {
+// Exchange the refresh token for a new access token; resolves to false when
+// there is no refresh token or the backend rejects it
+const refreshAccessToken = async () => {
+  const refreshToken = getRefreshToken();
+  if (!refreshToken) return false;
+
+  const response = await fetch(`${API_BASE_URL}/users/refresh`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  });
+  if (!response.ok) return false;
+
+  const data = await response.json();
+  localStorage.setItem('token', data.token);
+  localStorage.setItem('refreshToken', data.refresh_token);
+  return true;
+};
+
+// Helper function to make API requests; resolves to the raw response once
+// auth and error handling are done
+const apiFetch = async (endpoint, options = {}, retried = false) => {
   const url = `${API_BASE_URL}${endpoint}`;
   const token = getToken();
   
   const config = {
+    ...options,
     headers: {
       'Content-Type': 'application/json',
       ...(token && { 'Authorization': `Bearer ${token}` }),
       ...options.headers,
     },
-    ...options,
   };
 
   try {
     const response = await fetch(url, config);
     
+    // Access tokens are short-lived: refresh once and retry before giving up
+    if (response.status === 401 && token && !retried && await refreshAccessToken()) {
+      return apiFetch(endpoint, options, true);
+    }
+
     // Handle authentication errors
     if (response.status === 401 || response.status === 403) {
       // Clear invalid token and user data
@@ -28,17 +54,43 @@ const apiRequest = async (endpoint, options = {}) => {
       throw new Error('Authentication failed. Please login again.');
     }
     
+    // Errors are application/problem+json with a stable machine-readable code
     if (!response.ok) {
-      throw new Error(`HTTP error! status: ${response.status}`);
+      const problem = await response.json().catch(() => null);
+      const error = new Error(problem?.detail || `HTTP error! status: ${response.status}`);
+      error.status = response.status;
+      error.code = problem?.code;
+      throw error;
     }
     
-    return await response.json();
+    return response;
   } catch (error) {
     console.error('API request failed:', error);
     throw error;
   }
 };
 
+// Helper function to make API requests that return JSON
+const apiRequest = async (endpoint, options = {}) => {
+  const response = await apiFetch(endpoint, options);
+  return response.json();
+};
+
+// List endpoints are paginated; follow X-Next-Page until every page of a
+// list has been fetched
+const MAX_PAGE_SIZE = 100;
+const apiRequestAllPages = async (endpoint) => {
+  const separator = endpoint.includes('?') ? '&' : '?';
+  const results = [];
+  let page = 1;
+  while (page) {
+    const response = await apiFetch(`${endpoint}${separator}page=${page}&limit=${MAX_PAGE_SIZE}`);
+    results.push(...await response.json());
+    page = Number(response.headers.get('X-Next-Page')) || 0;
+  }
+  return results;
+};
+
 // Auth API functions
 export const authAPI = {
   // Create user (signup)
@@ -52,12 +104,17 @@ export const authAPI = {
     method: 'POST',
     body: JSON.stringify(credentials),
   }),
+
+  // Logout (revokes the current session and token)
+  logout: () => apiRequest('/users/logout', {
+    method: 'POST',
+  }),
 };
 
 // Items API functions
 export const itemsAPI = {
   // Get all items
-  getAll: () => apiRequest('/items'),
+  getAll: () => apiRequestAllPages('/items'),
 
   // Create item (admin function)
   create: (itemData) => apiRequest('/items', {
@@ -71,12 +128,20 @@ export const cartAPI = {
   // Get user's cart
   get: () => apiRequest('/carts'),
 
-  // Add item to cart
-  addItem: (itemId) => apiRequest('/carts', {
+  // Add item to cart. Pass the same idempotency key for every attempt at
+  // one add so a retried request cannot add the item twice.
+  addItem: (itemId, idempotencyKey) => apiRequest('/carts', {
     method: 'POST',
+    headers: idempotencyKey ? { 'Idempotency-Key': idempotencyKey } : {},
     body: JSON.stringify({ item_id: itemId }),
   }),
 
+  // Set item quantity in cart (0 removes the item)
+  updateQuantity: (itemId, quantity) => apiRequest(`/carts/${itemId}`, {
+    method: 'PUT',
+    body: JSON.stringify({ quantity }),
+  }),
+
   // Remove item from cart
   removeItem: (itemId) => apiRequest(`/carts/${itemId}`, {
     method: 'DELETE',
@@ -85,29 +150,35 @@ export const cartAPI = {
 
 // Orders API functions
 export const ordersAPI = {
-  // Create order (checkout)
-  create: () => apiRequest('/orders', {
+  // Create order (checkout). Pass the same idempotency key for every attempt
+  // at one checkout so a double-click or retry cannot place two orders.
+  create: (idempotencyKey) => apiRequest('/orders', {
     method: 'POST',
+    headers: idempotencyKey ? { 'Idempotency-Key': idempotencyKey } : {},
   }),
 
   // Get user's orders
-  getAll: () => apiRequest('/orders'),
+  getAll: () => apiRequestAllPages('/orders'),
 };
 
 // Authentication helpers
 export const auth = {
-  setToken: (token) => {
+  setToken: (token, refreshToken) => {
     // First, completely clear any existing data
     localStorage.clear();
     
     // Then set the new token
     localStorage.setItem('token', token);
+    if (refreshToken) {
+      localStorage.setItem('refreshToken', refreshToken);
+    }
     
     console.log('🔍 Auth: Token set and all localStorage cleared');
   },
   getToken,
   removeToken: () => {
     localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
     // Clear user-specific data when removing token
     auth.clearUserData();
   },